@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+	"github.com/lucasmenendez/gopsi/pkg/transport"
+)
+
+// readItems function reads the file provided returning every line as an item.
+func readItems(path string) (items []string) {
+	fd, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer fd.Close()
+
+	var scanner = bufio.NewScanner(fd)
+	for scanner.Scan() {
+		items = append(items, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		log.Fatalln(err)
+	}
+	return
+}
+
+// decodeHex function decodes the hex string provided, checking that it has the
+// size provided.
+func decodeHex(name, value string, size int) []byte {
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != size {
+		log.Fatalf("invalid %s, expected %d hex encoded bytes\n", name, size)
+	}
+	return decoded
+}
+
+// main function runs the PSI protocol over TCP between two processes. The
+// responder listens on the address provided and the initiator connects to it,
+// getting the intersection between both data files. Each process derives its
+// identity from a secret seed and only accepts the identity of the other one,
+// printed by the -seed flag alone. For example:
+//
+//	go run main.go -seed <bob seed>
+//	go run main.go -seed <alice seed>
+//	go run main.go -listen :8080 -data bob.txt -seed <bob seed> -peer <alice identity>
+//	go run main.go -connect localhost:8080 -data alice.txt -seed <alice seed> -peer <bob identity>
+func main() {
+	var listen = flag.String("listen", "", "address to listen on (responder)")
+	var connect = flag.String("connect", "", "address to connect to (initiator)")
+	var data = flag.String("data", "", "file with one item per line")
+	var seed = flag.String("seed", "", "hex encoded 32 bytes seed of the own identity")
+	var peer = flag.String("peer", "", "hex encoded identity of the other process")
+	flag.Parse()
+
+	if *seed == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var identity = ed25519.NewKeyFromSeed(decodeHex("seed", *seed, ed25519.SeedSize))
+	if *data == "" || *peer == "" || (*listen == "") == (*connect == "") {
+		fmt.Printf("identity: %x\n", identity.Public())
+		return
+	}
+	var items []string = readItems(*data)
+
+	// The common prime is agreed with parameters signed by the trusted
+	// identities, so the RSA key is not required.
+	psiClient, err := client.Init(
+		client.WithRSABits(0),
+		client.WithIdentity(identity),
+		client.WithPeerIdentity(decodeHex("peer", *peer, ed25519.PublicKeySize)),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// start the responder side, waiting for a single initiator
+	if *listen != "" {
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer conn.Close()
+
+		session := transport.NewSession(conn, psiClient, transport.Responder)
+		if _, err = session.Run(items); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("[responder] intersection computed with peer %x\n", psiClient.PeerIdentity())
+		return
+	}
+
+	// start the initiator side and print the intersection
+	conn, err := net.Dial("tcp", *connect)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer conn.Close()
+
+	session := transport.NewSession(conn, psiClient, transport.Initiator)
+	result, err := session.Run(items)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("[initiator] %d common items received from peer %x:\n", len(result), psiClient.PeerIdentity())
+	for i, item := range result {
+		fmt.Printf("\t%d. %v\n", i, item)
+	}
+}
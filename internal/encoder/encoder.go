@@ -1,9 +1,16 @@
 package encoder
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"math/big"
 )
 
+// HashDomain contains the domain separation tag used by StrToGroup to hash
+// items. It avoids that the resulting group elements collide with hashes
+// computed for other purposes over the same input.
+const HashDomain = "gopsi/v1/hash-to-group"
+
 // StrToInts function encodes the string provided into a slice of *big.Int's
 // iterating over input characters and storing each byte number representation.
 func StrToInts(input string) (encoded []*big.Int) {
@@ -25,3 +32,31 @@ func IntsToStr(input []*big.Int) string {
 
 	return string(decoded)
 }
+
+// StrToGroup function hashes the whole string provided into a single element
+// of the multiplicative group of integers modulo the prime provided. It expands
+// the input with SHA-256 in counter mode (prefixed by HashDomain) until it
+// gets 128 bits more than the prime size, and reduces the result into the
+// range [2, p-2] to avoid the trivial elements 1 and p-1, which are fixed
+// points of the SRA encryption. The result is not reversible, so the original
+// string must be kept by the caller to map it back.
+func StrToGroup(input string, prime *big.Int) *big.Int {
+	var size int = (prime.BitLen() + 128 + 7) / 8
+	var expanded []byte = make([]byte, 0, size+sha256.Size)
+	for counter := uint32(0); len(expanded) < size; counter++ {
+		var hash = sha256.New()
+		hash.Write([]byte{byte(len(HashDomain))})
+		hash.Write([]byte(HashDomain))
+		binary.Write(hash, binary.BigEndian, counter)
+		hash.Write([]byte(input))
+		expanded = hash.Sum(expanded)
+	}
+
+	// Reduce the expanded hash modulo (p - 3) and shift it by 2 to get a value
+	// into [2, p-2].
+	var bigTwo = big.NewInt(2)
+	var order = new(big.Int).Sub(prime, big.NewInt(3))
+	var element = new(big.Int).SetBytes(expanded[:size])
+	element.Mod(element, order)
+	return element.Add(element, bigTwo)
+}
@@ -1,6 +1,8 @@
 package encoder
 
 import (
+	"crypto/rand"
+	"math/big"
 	"testing"
 )
 
@@ -15,3 +17,21 @@ func TestStrToIntsToStr(t *testing.T) {
 		return
 	}
 }
+
+func TestStrToGroup(t *testing.T) {
+	prime, _ := rand.Prime(rand.Reader, 256)
+	var min, max = big.NewInt(2), new(big.Int).Sub(prime, big.NewInt(2))
+
+	first := StrToGroup("hello world", prime)
+	if first.Cmp(min) < 0 || first.Cmp(max) > 0 {
+		t.Errorf("Expected element into [2, p-2], got '%s'", first)
+	}
+
+	if second := StrToGroup("hello world", prime); first.Cmp(second) != 0 {
+		t.Errorf("Expected '%s', got '%s'", first, second)
+	}
+
+	if other := StrToGroup("hello world!", prime); first.Cmp(other) == 0 {
+		t.Errorf("Expected different elements for different inputs, got '%s'", other)
+	}
+}
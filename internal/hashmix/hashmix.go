@@ -0,0 +1,17 @@
+// Package hashmix implements the mixing of the hashes shared by the
+// membership filters, which reduce them to the ranges of their positions.
+package hashmix
+
+// Mix64 function returns the hash provided mixed with the finalizer of
+// MurmurHash3, so every bit of the result depends on every bit of the hash.
+// The bits of weak hash functions such as FNV-1a do not distribute similar
+// items evenly, so it is required before reducing a hash to a range or
+// splitting it into independent parts.
+func Mix64(hashed uint64) uint64 {
+	hashed ^= hashed >> 33
+	hashed *= 0xff51afd7ed558ccd
+	hashed ^= hashed >> 33
+	hashed *= 0xc4ceb9fe1a85ec53
+	hashed ^= hashed >> 33
+	return hashed
+}
@@ -0,0 +1,18 @@
+package hashmix
+
+import "testing"
+
+func TestMix64(t *testing.T) {
+	var cases = []struct {
+		input, expected uint64
+	}{
+		{0, 0},
+		{1, 0xb456bcfc34c2cb2c},
+		{0xcbf29ce484222325, 0xefd01f60ba992926},
+	}
+	for _, c := range cases {
+		if result := Mix64(c.input); result != c.expected {
+			t.Errorf("expected %x for %x, got %x", c.expected, c.input, result)
+		}
+	}
+}
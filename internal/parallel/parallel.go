@@ -0,0 +1,101 @@
+// Package parallel implements a simple worker pool to process batches of items
+// across multiple goroutines.
+package parallel
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// DefaultChunkSize contains the default number of consecutive items processed
+// by a worker at once.
+const DefaultChunkSize = 64
+
+// Run function calls fn for every index of the range [0, n), splitting it into
+// chunks of chunkSize consecutive indexes that are processed by up to workers
+// goroutines. The order of the calls is not defined, so fn must store its
+// results by index to keep the output order deterministic. If workers is not
+// positive, runtime.GOMAXPROCS(0) workers are used, and if chunkSize is not
+// positive, DefaultChunkSize is used. The context provided is checked between
+// items, and the first error returned by fn (or the context error) stops the
+// remaining work and is returned.
+func Run(ctx context.Context, n, workers, chunkSize int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	var chunks int = (n + chunkSize - 1) / chunkSize
+	if workers > chunks {
+		workers = chunks
+	}
+
+	// Process the items in the current goroutine if there is a single worker.
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			} else if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var firstErr error
+	var fail = func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	var queue = make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range queue {
+				var end int = chunk + chunkSize
+				if end > n {
+					end = n
+				}
+
+				for i := chunk; i < end; i++ {
+					if err := ctx.Err(); err != nil {
+						fail(err)
+						break
+					} else if err := fn(i); err != nil {
+						fail(err)
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	// Send the chunks to the workers until all of them are sent or the
+	// context is done.
+	for chunk := 0; chunk < n; chunk += chunkSize {
+		select {
+		case queue <- chunk:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,58 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 16} {
+		var output []int = make([]int, 1000)
+		err := Run(context.Background(), len(output), workers, 7, func(i int) error {
+			output[i] = i * i
+			return nil
+		})
+
+		if err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+		for i, result := range output {
+			if result != i*i {
+				t.Fatalf("expected %d, got %d", i*i, result)
+			}
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	var expected = errors.New("item error")
+	for _, workers := range []int{1, 4} {
+		err := Run(context.Background(), 1000, workers, 10, func(i int) error {
+			if i == 500 {
+				return expected
+			}
+			return nil
+		})
+
+		if err != expected {
+			t.Fatalf("expected %v, got %v", expected, err)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := Run(ctx, 1000, workers, 10, func(i int) error {
+			if i == 100 {
+				cancel()
+			}
+			return nil
+		})
+
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+}
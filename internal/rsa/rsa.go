@@ -1,6 +1,17 @@
+// Package rsa wraps the RSA keys used by the clients to exchange the common
+// prime. The messages are encrypted with a hybrid scheme (read more in
+// RSAKey.Encrypt), whose format starts with a version byte (hybridVersion):
+//
+//	hybridVersion (1 byte) || RSA-OAEP(session key) || nonce || AES-GCM(msg)
+//
+// The previous versions encrypted the messages with RSA-OAEP only, so they
+// cannot decrypt the hybrid format, but RSAKey.Decrypt still accepts their
+// messages, which have the size of the key.
 package rsa
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -8,6 +19,14 @@ import (
 	"errors"
 )
 
+// sessionKeySize contains the size of the AES keys used to encrypt messages
+// larger than the RSA key size.
+const sessionKeySize = 32
+
+// hybridVersion contains the first byte of the messages encrypted with the
+// hybrid scheme, which identifies the version of their format.
+const hybridVersion byte = 0x01
+
 type RSAKey struct {
 	pub  *rsa.PublicKey
 	priv *rsa.PrivateKey
@@ -28,16 +47,78 @@ func NewKey(size int) (key *RSAKey, err error) {
 	return
 }
 
-func (key *RSAKey) Encrypt(msg []byte) (cipher []byte, err error) {
-	cipher, err = rsa.EncryptOAEP(sha1.New(), rand.Reader, key.pub, msg, nil)
-	return
+// Size function returns the size of the key modulus in bits.
+func (key *RSAKey) Size() int {
+	return key.pub.N.BitLen()
 }
 
-func (key *RSAKey) Decrypt(cipher []byte) (msg []byte, err error) {
-	msg, err = rsa.DecryptOAEP(sha1.New(), rand.Reader, key.priv, cipher, nil)
+// Encrypt function encrypts the message provided using a hybrid scheme: a
+// random AES-256 session key is encrypted with RSA-OAEP and the message is
+// encrypted with AES-GCM using the session key. It allows to encrypt messages
+// larger than the RSA key size, such as big primes. The result contains the
+// format version (hybridVersion), the encrypted session key, the GCM nonce and
+// the encrypted message, authenticating the version and the session key too.
+func (key *RSAKey) Encrypt(msg []byte) (result []byte, err error) {
+	var sessionKey []byte = make([]byte, sessionKeySize)
+	if _, err = rand.Read(sessionKey); err != nil {
+		return
+	}
+
+	var encKey []byte
+	if encKey, err = rsa.EncryptOAEP(sha1.New(), rand.Reader, key.pub, sessionKey, nil); err != nil {
+		return
+	}
+
+	var aead cipher.AEAD
+	if aead, err = newAEAD(sessionKey); err != nil {
+		return
+	}
+	var nonce []byte = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	result = append([]byte{hybridVersion}, encKey...)
+	var header int = len(result)
+	result = append(result, nonce...)
+	result = aead.Seal(result, nonce, msg, result[:header])
 	return
 }
 
+// Decrypt function decrypts a message encrypted with RSAKey.Encrypt, decrypting
+// the session key with RSA-OAEP first and the message with AES-GCM then. The
+// messages with the size of the key are decrypted with RSA-OAEP only, as the
+// previous versions encrypted them. It returns an error if the format version
+// is not supported.
+func (key *RSAKey) Decrypt(result []byte) (msg []byte, err error) {
+	var keySize int = key.priv.Size()
+	if len(result) == keySize {
+		return rsa.DecryptOAEP(sha1.New(), rand.Reader, key.priv, result, nil)
+	} else if len(result) < 1+keySize {
+		return nil, errors.New("encrypted message too short")
+	} else if result[0] != hybridVersion {
+		return nil, errors.New("unsupported encrypted message version")
+	}
+
+	var header []byte = result[:1+keySize]
+	var encKey, sessionKey []byte = header[1:], nil
+	if sessionKey, err = rsa.DecryptOAEP(sha1.New(), rand.Reader, key.priv, encKey, nil); err != nil {
+		return
+	} else if len(sessionKey) != sessionKeySize {
+		return nil, errors.New("invalid session key size")
+	}
+
+	var aead cipher.AEAD
+	if aead, err = newAEAD(sessionKey); err != nil {
+		return
+	} else if len(result) < len(header)+aead.NonceSize() {
+		return nil, errors.New("encrypted message too short")
+	}
+
+	var nonce []byte = result[len(header) : len(header)+aead.NonceSize()]
+	return aead.Open(nil, nonce, result[len(header)+aead.NonceSize():], header)
+}
+
 func (key *RSAKey) PubKey() (pub []byte, err error) {
 	if pub, err = x509.MarshalPKIXPublicKey(key.pub); err != nil {
 		return
@@ -46,7 +127,9 @@ func (key *RSAKey) PubKey() (pub []byte, err error) {
 	return
 }
 
-func EncryptWitPubKey(pub, msg []byte) ([]byte, error) {
+// ParsePubKey function parses the encoded RSA public key provided, returning
+// an RSAKey that only allows to encrypt.
+func ParsePubKey(pub []byte) (*RSAKey, error) {
 	var ok bool
 	var key *RSAKey = &RSAKey{}
 	if candidate, err := x509.ParsePKIXPublicKey(pub); err != nil {
@@ -55,5 +138,23 @@ func EncryptWitPubKey(pub, msg []byte) ([]byte, error) {
 		return nil, errors.New("error casting public key to *rsa.PublicKey")
 	}
 
+	return key, nil
+}
+
+func EncryptWitPubKey(pub, msg []byte) ([]byte, error) {
+	key, err := ParsePubKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
 	return key.Encrypt(msg)
 }
+
+// newAEAD function instances an AES-GCM cipher with the key provided.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -3,6 +3,8 @@ package rsa
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
 	"math/big"
 	"testing"
 )
@@ -104,3 +106,59 @@ func TestEncryptPk(t *testing.T) {
 		return
 	}
 }
+
+func TestEncryptLargeMessage(t *testing.T) {
+	keys, _ := NewKey(2048)
+	if keys.Size() != 2048 {
+		t.Errorf("Expected key size 2048, got %d", keys.Size())
+		return
+	}
+
+	var input []byte = make([]byte, 1024)
+	rand.Read(input)
+
+	pk, _ := keys.PubKey()
+	cipher, err := EncryptWitPubKey(pk, input)
+	if err != nil {
+		t.Errorf("Expected success during input encryption, got error: %s", err)
+		return
+	}
+
+	plain, err := keys.Decrypt(cipher)
+	if err != nil {
+		t.Errorf("Expected success during input decryption, got error: %s", err)
+		return
+	} else if !bytes.Equal(input, plain) {
+		t.Errorf("Expected decrypted message equal to the input")
+		return
+	}
+
+	cipher[len(cipher)-1] ^= 0xff
+	if _, err = keys.Decrypt(cipher); err == nil {
+		t.Errorf("Expected error decrypting a modified message, got nil")
+	} else if _, err = keys.Decrypt(cipher[:10]); err == nil {
+		t.Errorf("Expected error decrypting a truncated message, got nil")
+	}
+}
+
+func TestDecryptFormats(t *testing.T) {
+	keys, _ := NewKey(1024)
+	var input []byte = []byte("hello world")
+
+	// Messages encrypted with RSA-OAEP only by the previous versions.
+	legacy, _ := rsa.EncryptOAEP(sha1.New(), rand.Reader, keys.pub, input, nil)
+	if plain, err := keys.Decrypt(legacy); err != nil {
+		t.Errorf("Expected success during legacy decryption, got error: %s", err)
+	} else if !bytes.Equal(input, plain) {
+		t.Errorf("Expected '%s', got '%s'", input, plain)
+	}
+
+	cipher, _ := keys.Encrypt(input)
+	if cipher[0] != hybridVersion {
+		t.Errorf("Expected version %d, got %d", hybridVersion, cipher[0])
+	}
+	cipher[0] = hybridVersion + 1
+	if _, err := keys.Decrypt(cipher); err == nil {
+		t.Errorf("Expected error decrypting an unknown version, got nil")
+	}
+}
@@ -0,0 +1,124 @@
+package bloomfilter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// ErrIncompatible is returned when two filters cannot be combined because
+// their number of bits, number of hash functions or hash function differ.
+var ErrIncompatible = errors.New("bloomfilter: incompatible filters")
+
+// hasherProbe contains the input hashed by both filters to check that their
+// hash functions are the same, including the key of the keyed ones.
+var hasherProbe = []byte("gopsi/v1/bloomfilter/probe")
+
+// compatible function returns ErrIncompatible if the filter provided has not
+// the same number of bits, number of hash functions and hash function as the
+// current one. The hash functions are the same if they use the same algorithm
+// and return the same hash for a fixed input, so filters with keyed hash
+// functions with different keys are not compatible. Compatible filters set the
+// same positions for every item (read more in params.position), so their
+// bitmaps can be combined word by word.
+func (f *BloomFilter) compatible(other *BloomFilter) error {
+	if f.m != other.m {
+		return fmt.Errorf("%w: different number of bits", ErrIncompatible)
+	} else if f.k != other.k {
+		return fmt.Errorf("%w: different number of hash functions", ErrIncompatible)
+	} else if f.hasher.Algorithm() != other.hasher.Algorithm() || f.hasher.Sum64(hasherProbe) != other.hasher.Sum64(hasherProbe) {
+		return fmt.Errorf("%w: different hash function", ErrIncompatible)
+	}
+	return nil
+}
+
+// combine function returns a new filter with the parameters of the current
+// one, whose words are the result of the operation provided with the words of
+// both filters.
+func (f *BloomFilter) combine(other *BloomFilter, operation func(a, b uint64) uint64) (*BloomFilter, error) {
+	if err := f.compatible(other); err != nil {
+		return nil, err
+	}
+
+	var result = &BloomFilter{params: f.params, data: newBitset(f.m)}
+	for i := range result.data {
+		result.data[i] = operation(atomic.LoadUint64(&f.data[i]), atomic.LoadUint64(&other.data[i]))
+	}
+	return result, nil
+}
+
+// Union function returns a new filter that contains the items of both the
+// current filter and the one provided, setting the bits set in any of them. It
+// is the same filter that would result of adding the items of both filters to
+// a single one, so it allows to merge filters prepared separately, for
+// example, by different shards. Its false positive rate grows with the number
+// of items of both filters. It returns ErrIncompatible if the filters do not
+// have the same parameters.
+func (f *BloomFilter) Union(other *BloomFilter) (*BloomFilter, error) {
+	return f.combine(other, func(a, b uint64) uint64 { return a | b })
+}
+
+// Intersect function returns a new filter that contains the items of both the
+// current filter and the one provided, setting the bits set in both of them.
+// It contains every common item, but its false positive rate is higher than
+// the one of a filter with only the common items, because the bits set by
+// different items of each filter can coincide. It returns ErrIncompatible if
+// the filters do not have the same parameters.
+func (f *BloomFilter) Intersect(other *BloomFilter) (*BloomFilter, error) {
+	return f.combine(other, func(a, b uint64) uint64 { return a & b })
+}
+
+// EstimatedCardinality function returns the estimated number of different
+// items added to the filter according to the bits set (X), following the
+// formula: n = -(m / k) * ln(1 - X / m). It returns +Inf if every bit is set.
+// Read more about it here: https://doi.org/10.1021/ci600526a.
+func (f *BloomFilter) EstimatedCardinality() float64 {
+	return -float64(f.m) / float64(f.k) * math.Log(1-f.FillRatio())
+}
+
+// EstimatedIntersectionCardinality function returns the estimated number of
+// items contained by both the current filter and the one provided, following
+// the inclusion-exclusion principle: |A ∩ B| = |A| + |B| - |A ∪ B|, with the
+// cardinality of each set estimated by EstimatedCardinality. It is more
+// accurate than the estimated cardinality of the Intersect filter, that
+// includes the coincident bits of different items. It returns NaN if every
+// bit of any filter is set, because their number of items cannot be
+// estimated, and ErrIncompatible if the filters do not have the same
+// parameters.
+func (f *BloomFilter) EstimatedIntersectionCardinality(other *BloomFilter) (float64, error) {
+	union, err := f.Union(other)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64 = union.EstimatedCardinality()
+	if math.IsInf(total, 1) {
+		return math.NaN(), nil
+	}
+	return math.Max(0, f.EstimatedCardinality()+other.EstimatedCardinality()-total), nil
+}
+
+// EstimatedJaccard function returns the estimated Jaccard similarity of the
+// items of the current filter and the one provided, the ratio between the
+// number of common items and the total number of items, |A ∩ B| / |A ∪ B|,
+// between 0 and 1. It allows to estimate the overlap of two sets before
+// calculating their private intersection. It returns 0 if both filters are
+// empty, NaN if every bit of any filter is set, as
+// EstimatedIntersectionCardinality does, and ErrIncompatible if the filters do
+// not have the same parameters.
+func (f *BloomFilter) EstimatedJaccard(other *BloomFilter) (float64, error) {
+	union, err := f.Union(other)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64 = union.EstimatedCardinality()
+	if math.IsInf(total, 1) {
+		return math.NaN(), nil
+	} else if total == 0 {
+		return 0, nil
+	}
+	var common float64 = f.EstimatedCardinality() + other.EstimatedCardinality() - total
+	return math.Max(0, math.Min(1, common/total)), nil
+}
@@ -0,0 +1,137 @@
+package bloomfilter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// algebraItems function returns the items from the start to the end provided.
+func algebraItems(start, end int) [][]byte {
+	items := make([][]byte, 0, end-start)
+	for i := start; i < end; i++ {
+		items = append(items, []byte(fmt.Sprintf("item-%d", i)))
+	}
+	return items
+}
+
+func TestUnion(t *testing.T) {
+	itemsA, itemsB := algebraItems(0, 600), algebraItems(400, 1000)
+	filterA, filterB, expected := NewFilter(1000, 0.01), NewFilter(1000, 0.01), NewFilter(1000, 0.01)
+	filterA.Add(itemsA...)
+	filterB.Add(itemsB...)
+	expected.Add(itemsA...)
+	expected.Add(itemsB...)
+
+	// The union is the filter with the items of both filters.
+	union, err := filterA.Union(filterB)
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if !reflect.DeepEqual(union.data, expected.data) {
+		t.Fatal("Expected union equal to the filter with every item")
+	} else if union.M() != filterA.M() || union.K() != filterA.K() || union.N() != filterA.N() {
+		t.Fatalf("Expected m, k, n = %d, %d, %d, got %d, %d, %d", filterA.M(), filterA.K(), filterA.N(), union.M(), union.K(), union.N())
+	}
+
+	// The filters are not modified.
+	if filterA.Test(itemsB[len(itemsB)-1]) {
+		t.Errorf("Expected that filter not contains '%s'.", itemsB[len(itemsB)-1])
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	itemsA, itemsB := algebraItems(0, 600), algebraItems(400, 1000)
+	filterA, filterB := NewFilter(1000, 0.01), NewFilter(1000, 0.01)
+	filterA.Add(itemsA...)
+	filterB.Add(itemsB...)
+
+	intersection, err := filterA.Intersect(filterB)
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	}
+	for i, result := range intersection.TestMultiple(algebraItems(400, 600)...) {
+		if !result {
+			t.Fatalf("Expected that intersection contains 'item-%d'.", 400+i)
+		}
+	}
+
+	// Most of the items of a single filter are not into the intersection.
+	var positives int
+	for _, result := range intersection.TestMultiple(algebraItems(0, 400)...) {
+		if result {
+			positives++
+		}
+	}
+	if positives > 40 {
+		t.Errorf("Expected less than 40 false positives, got %d", positives)
+	}
+}
+
+func TestEstimatedCardinality(t *testing.T) {
+	filterA, filterB := NewFilter(10000, 0.01), NewFilter(10000, 0.01)
+	if cardinality := filterA.EstimatedCardinality(); cardinality != 0 {
+		t.Errorf("Expected 0 items, got %f", cardinality)
+	} else if jaccard, err := filterA.EstimatedJaccard(filterB); err != nil || jaccard != 0 {
+		t.Errorf("Expected 0 and nil, got %f and %v", jaccard, err)
+	}
+
+	// 6000 items each, 2000 common items: Jaccard similarity 2000 / 10000.
+	filterA.Add(algebraItems(0, 6000)...)
+	filterB.Add(algebraItems(4000, 10000)...)
+	if cardinality := filterA.EstimatedCardinality(); math.Abs(cardinality-6000) > 6000*0.05 {
+		t.Errorf("Expected around 6000 items, got %f", cardinality)
+	}
+	if common, err := filterA.EstimatedIntersectionCardinality(filterB); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if math.Abs(common-2000) > 10000*0.05 {
+		t.Errorf("Expected around 2000 common items, got %f", common)
+	}
+	if jaccard, err := filterA.EstimatedJaccard(filterB); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if math.Abs(jaccard-0.2) > 0.05 {
+		t.Errorf("Expected Jaccard similarity around 0.2, got %f", jaccard)
+	}
+
+	// The same filter is completely similar.
+	if jaccard, _ := filterA.EstimatedJaccard(filterA); jaccard != 1 {
+		t.Errorf("Expected Jaccard similarity 1, got %f", jaccard)
+	}
+
+	// The number of items of a full filter cannot be estimated.
+	full := NewFilter(10, 0.01)
+	for i := uint(0); i < full.M(); i++ {
+		full.data.set(i)
+	}
+	if cardinality := full.EstimatedCardinality(); !math.IsInf(cardinality, 1) {
+		t.Errorf("Expected +Inf, got %f", cardinality)
+	} else if jaccard, _ := full.EstimatedJaccard(NewFilter(10, 0.01)); !math.IsNaN(jaccard) {
+		t.Errorf("Expected NaN, got %f", jaccard)
+	}
+}
+
+func TestIncompatibleFilters(t *testing.T) {
+	filter := NewFilter(1000, 0.01, WithHasher(SipHasher([16]byte{1})))
+	others := map[string]*BloomFilter{
+		"m":      NewFilter(2000, 0.01, WithHasher(SipHasher([16]byte{1}))),
+		"k":      {params: params{m: filter.m, k: filter.k + 1, n: filter.n, hasher: filter.hasher}, data: newBitset(filter.m)},
+		"hasher": NewFilter(1000, 0.01, WithHasher(FNVHasher())),
+		"key":    NewFilter(1000, 0.01, WithHasher(SipHasher([16]byte{2}))),
+	}
+	for name, other := range others {
+		if _, err := filter.Union(other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Expected %v for different %s, got %v", ErrIncompatible, name, err)
+		} else if _, err = filter.Intersect(other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Expected %v for different %s, got %v", ErrIncompatible, name, err)
+		} else if _, err = filter.EstimatedIntersectionCardinality(other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Expected %v for different %s, got %v", ErrIncompatible, name, err)
+		} else if _, err = filter.EstimatedJaccard(other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Expected %v for different %s, got %v", ErrIncompatible, name, err)
+		}
+	}
+
+	if _, err := filter.Union(NewFilter(1000, 0.01, WithHasher(SipHasher([16]byte{1})))); err != nil {
+		t.Errorf("Expected nil, got %s", err)
+	}
+}
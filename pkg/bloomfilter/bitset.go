@@ -0,0 +1,47 @@
+package bloomfilter
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// wordSize contains the number of bits of each word of a bitset.
+const wordSize = 64
+
+// bitset type implements a packed bitmap that stores 64 bits into each word,
+// using 1 bit of memory per bit instead of the 8 bits of a []bool. Its words
+// are accessed atomically, so it is safe for concurrent use.
+type bitset []uint64
+
+// newBitset function returns a bitset with enough words to store the number
+// of bits provided.
+func newBitset(size uint) bitset {
+	return make(bitset, (size+wordSize-1)/wordSize)
+}
+
+// set function sets the bit of the index provided, retrying the atomic
+// compare-and-swap of its word until no other goroutine has modified it in the
+// meantime.
+func (b bitset) set(index uint) {
+	var word *uint64 = &b[index/wordSize]
+	var mask uint64 = 1 << (index % wordSize)
+	for {
+		var old uint64 = atomic.LoadUint64(word)
+		if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+			return
+		}
+	}
+}
+
+// test function returns if the bit of the index provided is set.
+func (b bitset) test(index uint) bool {
+	return atomic.LoadUint64(&b[index/wordSize])&(1<<(index%wordSize)) != 0
+}
+
+// count function returns the number of bits set.
+func (b bitset) count() (total uint) {
+	for i := range b {
+		total += uint(bits.OnesCount64(atomic.LoadUint64(&b[i])))
+	}
+	return
+}
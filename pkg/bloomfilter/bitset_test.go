@@ -0,0 +1,107 @@
+package bloomfilter
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+// boolBitset type implements the previous []bool bitmap layout to compare it
+// with the packed bitset in the benchmarks.
+type boolBitset []bool
+
+func (b boolBitset) set(index uint) {
+	b[index] = true
+}
+
+func (b boolBitset) test(index uint) bool {
+	return b[index]
+}
+
+func TestBitset(t *testing.T) {
+	var size uint = 130
+	data := newBitset(size)
+	if len(data) != 3 {
+		t.Errorf("Expected 3 words, got %d", len(data))
+	}
+
+	indexes := []uint{0, 1, 63, 64, 127, 129}
+	for _, i := range indexes {
+		data.set(i)
+	}
+	for _, i := range indexes {
+		if !data.test(i) {
+			t.Errorf("Expected bit %d set", i)
+		}
+	}
+	for _, i := range []uint{2, 62, 65, 128} {
+		if data.test(i) {
+			t.Errorf("Expected bit %d not set", i)
+		}
+	}
+	if count := data.count(); count != uint(len(indexes)) {
+		t.Errorf("Expected %d bits set, got %d", len(indexes), count)
+	}
+}
+
+// benchmarkIndexes function returns a pseudorandom sequence of indexes into
+// the range of the size provided, using the same Kirsch-Mitzenmacher
+// combination of the filter.
+func benchmarkIndexes(size uint) []uint {
+	indexes := make([]uint, 1<<16)
+	var a, b uint = 0x9e3779b9, 0x7f4a7c15
+	for i := range indexes {
+		indexes[i] = (a + uint(i)*b) % size
+	}
+	return indexes
+}
+
+func BenchmarkBitset(b *testing.B) {
+	for _, n := range []int{1000, 1000000} {
+		m := NewFilter(n, 0.0001).M()
+		indexes := benchmarkIndexes(m)
+
+		b.Run(fmt.Sprintf("packed/n=%d", n), func(b *testing.B) {
+			data := newBitset(m)
+			for i := 0; i < b.N; i++ {
+				index := indexes[i%len(indexes)]
+				data.set(index)
+				if !data.test(index) {
+					b.Fatal("Expected bit set")
+				}
+			}
+			b.ReportMetric(float64(len(data))*float64(unsafe.Sizeof(uint64(0))), "bytes")
+		})
+
+		b.Run(fmt.Sprintf("bool/n=%d", n), func(b *testing.B) {
+			data := make(boolBitset, m)
+			for i := 0; i < b.N; i++ {
+				index := indexes[i%len(indexes)]
+				data.set(index)
+				if !data.test(index) {
+					b.Fatal("Expected bit set")
+				}
+			}
+			b.ReportMetric(float64(len(data))*float64(unsafe.Sizeof(false)), "bytes")
+		})
+	}
+}
+
+func BenchmarkFilter(b *testing.B) {
+	items := make([][]byte, 10000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	filter := NewFilter(len(items), 0.0001)
+
+	b.Run("Add", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filter.Add(items[i%len(items)])
+		}
+	})
+	b.Run("Test", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filter.Test(items[i%len(items)])
+		}
+	})
+}
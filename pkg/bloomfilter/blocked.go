@@ -0,0 +1,194 @@
+package bloomfilter
+
+import (
+	"math"
+	"math/bits"
+	"unsafe"
+
+	"github.com/lucasmenendez/gopsi/internal/hashmix"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+// Layout of the bitmap of a BlockedFilter: 512 bits per block, the 64 bytes of
+// a cache line, 8 words per block.
+const (
+	blockBits  = 512
+	blockWords = blockBits / wordSize
+	blockBytes = blockBits / 8
+)
+
+// Positions into a block calculated with each 64-bit hash: 9 bits per
+// position, so 7 positions per hash.
+const (
+	positionBits  = 9
+	positionMask  = 1<<positionBits - 1
+	hashPositions = wordSize / positionBits
+)
+
+// BlockedFilter struct contains the required parameters to create and use a
+// blocked Bloom filter, that splits its bitmap into blocks of 512 bits aligned
+// to the cache lines of the CPU and sets every position of an item into a
+// single block, chosen by its hash. A BloomFilter spreads the k positions of
+// an item across the whole bitmap, so testing an item of a large filter
+// requires up to k cache misses, while a BlockedFilter requires only one. The
+// items are not distributed as evenly, so it requires slightly more bits than
+// a BloomFilter with the same false positive rate. As BloomFilter, it is safe
+// to call Add, Test and TestMultiple concurrently. Read more about blocked
+// Bloom filters here: https://algo2.iti.kit.edu/documents/cacheefficientbloomfilters-jea.pdf.
+type BlockedFilter struct {
+	params
+	blocks uint   // number of blocks
+	data   bitset // filter content
+}
+
+// NewBlockedFilter function initializes a new BlockedFilter with the size,
+// false positive rate and options provided. It calculates the number of hash
+// functions as NewFilter does, but the number of bits is the minimum number of
+// blocks that keeps the false positive rate provided, which requires around 5%
+// more bits than a BloomFilter for a false positive rate of 0.01 and 15% for
+// 0.0001.
+func NewBlockedFilter(size int, fp float64, options ...Option) *BlockedFilter {
+	var p params = newParams(size, fp, options)
+	var blocks uint = p.numberOfBlocks(fp)
+	p.m = blocks * blockBits
+	return &BlockedFilter{params: p, blocks: blocks, data: newBlocks(blocks)}
+}
+
+// newBlocks function returns a bitset with the words of the number of blocks
+// provided, starting at an address aligned to the size of a block, so each
+// block fits into a single cache line.
+func newBlocks(blocks uint) bitset {
+	var data bitset = make(bitset, (blocks+1)*blockWords)
+	var offset uintptr = uintptr(unsafe.Pointer(&data[0])) % blockBytes
+	var start uint = uint(blockBytes-offset) % blockBytes / 8
+	return data[start : start+blocks*blockWords : start+blocks*blockWords]
+}
+
+// numberOfBlocks function calculates the minimum number of blocks to store the
+// current size of the filter (n) with the provided false positive rate (fp),
+// starting with the blocks of the optimal number of bits of a BloomFilter and
+// adding blocks until the false positive rate of the blocked filter is lower
+// than the one provided.
+func (p *params) numberOfBlocks(fp float64) uint {
+	var blocks uint = (p.m + blockBits - 1) / blockBits
+	for fp > 0 && blockedFalsePositiveRate(p.n, blocks, p.k) > fp {
+		blocks += blocks/64 + 1
+	}
+	return blocks
+}
+
+// blockedFalsePositiveRate function returns the false positive rate of a
+// blocked filter with the number of items, blocks and hash functions provided.
+// The number of items of each block follows a Poisson distribution with mean
+// n / blocks, so the false positive rate is the sum of the false positive rate
+// of a block with i items, (1 - (1 - 1/512)^(i * k))^k, weighted by the
+// probability of a block with i items, for every number of items that is
+// likely enough to change the result.
+func blockedFalsePositiveRate(n, blocks, k uint) (fp float64) {
+	var mean float64 = float64(n) / float64(blocks)
+	var limit float64 = mean + 10*math.Sqrt(mean) + 10
+	for i := float64(0); i <= limit; i++ {
+		var lgamma, _ = math.Lgamma(i + 1)
+		var probability float64 = math.Exp(i*math.Log(mean) - mean - lgamma)
+		fp += probability * math.Pow(1-math.Pow(1-1.0/blockBits, i*float64(k)), float64(k))
+	}
+	return
+}
+
+// locate function returns the first bit of the block of the item provided,
+// chosen by the high bits of its hash, and the hash itself to calculate its
+// positions into the block. The hash is mixed first (read more in hashmix.Mix64) to
+// distribute similar items evenly between the blocks.
+func (f *BlockedFilter) locate(item []byte) (uint, uint64) {
+	var hashed uint64 = hashmix.Mix64(f.hasher.Sum64(item))
+	var block, _ = bits.Mul64(hashed, uint64(f.blocks))
+	return uint(block) * blockBits, hashed
+}
+
+// positions function returns the i-th group of 7 positions into the block of
+// the item with the hash provided, packed into 9 bits each, using the i-th
+// value of a SplitMix64 sequence seeded with the hash. The Kirsch-Mitzenmacher
+// combination of BloomFilter is not used because, into a block of only 512
+// bits, the positions of many items share most of their bits, which increases
+// the false positive rate.
+func positions(hashed uint64, i uint) uint64 {
+	var z uint64 = hashed + uint64(i+1)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// Blocks function returns the number of blocks of the filter.
+func (f *BlockedFilter) Blocks() uint {
+	return f.blocks
+}
+
+// FillRatio function returns the ratio of bits of the filter that are set.
+func (f *BlockedFilter) FillRatio() float64 {
+	return float64(f.data.count()) / float64(f.m)
+}
+
+// EstimatedFalsePositiveRate function returns the false positive rate of the
+// filter according to the bits actually set. The bits set are not distributed
+// evenly between the blocks, so it is the average of the false positive rate
+// of every block, following the formula of
+// BloomFilter.EstimatedFalsePositiveRate with the fill ratio of each block.
+func (f *BlockedFilter) EstimatedFalsePositiveRate() float64 {
+	var fp float64
+	for i := uint(0); i < f.blocks; i++ {
+		var block bitset = f.data[i*blockWords : (i+1)*blockWords]
+		fp += math.Pow(float64(block.count())/blockBits, float64(f.k))
+	}
+	return fp / float64(f.blocks)
+}
+
+// Add function inserts one (or more) items into the filter, setting the k
+// positions of each item into its block. It never fails, it returns an error
+// to implement membership.Filter.
+func (f *BlockedFilter) Add(items ...[]byte) error {
+	for _, item := range items {
+		var block, hashed = f.locate(item)
+		var packed uint64
+		for i := uint(0); i < f.k; i++ {
+			if i%hashPositions == 0 {
+				packed = positions(hashed, i/hashPositions)
+			}
+			f.data.set(block + uint(packed&positionMask))
+			packed >>= positionBits
+		}
+	}
+	return nil
+}
+
+// Remove function returns membership.ErrUnsupported, because the bits of a
+// blocked Bloom filter can be shared by many items, as BloomFilter.Remove does.
+func (f *BlockedFilter) Remove(items ...[]byte) error {
+	return membership.ErrUnsupported
+}
+
+// Test function checks if the filter contains the item provided, which is true
+// if every position of the item into its block is set.
+func (f *BlockedFilter) Test(item []byte) bool {
+	var block, hashed = f.locate(item)
+	var packed uint64
+	for i := uint(0); i < f.k; i++ {
+		if i%hashPositions == 0 {
+			packed = positions(hashed, i/hashPositions)
+		}
+		if !f.data.test(block + uint(packed&positionMask)) {
+			return false
+		}
+		packed >>= positionBits
+	}
+	return true
+}
+
+// TestMultiple function tests multiple items at the same time, using the
+// BlockedFilter.Test function.
+func (f *BlockedFilter) TestMultiple(items ...[]byte) (results []bool) {
+	results = make([]bool, len(items))
+	for i, item := range items {
+		results[i] = f.Test(item)
+	}
+	return
+}
@@ -0,0 +1,145 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+func TestBlockedFilter(t *testing.T) {
+	var n, fp = 10000, 0.001
+	filter := NewBlockedFilter(n, fp)
+	if filter.M()%blockBits != 0 || filter.M() != filter.Blocks()*blockBits {
+		t.Fatalf("Expected m multiple of %d, got %d", blockBits, filter.M())
+	} else if m := NewFilter(n, fp).M(); filter.M() < m {
+		t.Fatalf("Expected at least %d bits, got %d", m, filter.M())
+	} else if address := uintptr(unsafe.Pointer(&filter.data[0])); address%blockBytes != 0 {
+		t.Fatalf("Expected blocks aligned to %d bytes, got address %x", blockBytes, address)
+	}
+
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	filter.Add(items...)
+	for i, result := range filter.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", items[i])
+		}
+	}
+
+	// Every position of an item is into the same block.
+	block, _ := filter.locate(items[0])
+	for i := block; i < block+blockBits; i++ {
+		filter.data[i/wordSize] = 0
+	}
+	if filter.Test(items[0]) {
+		t.Errorf("Expected that filter not contains '%s'.", items[0])
+	}
+
+	// Check the estimated and the actual false positive rates.
+	estimated := filter.EstimatedFalsePositiveRate()
+	if estimated < fp/2 || estimated > fp*2 {
+		t.Errorf("Expected estimated false positive rate close to %f, got %f", fp, estimated)
+	}
+	var positives, tests = 0, 100000
+	for i := 0; i < tests; i++ {
+		if filter.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(tests); actual > fp*2 {
+		t.Errorf("Expected false positive rate close to %f, got %f", fp, actual)
+	}
+
+	if err := filter.Remove(items[0]); !errors.Is(err, membership.ErrUnsupported) {
+		t.Errorf("Expected %v, got %v", membership.ErrUnsupported, err)
+	}
+}
+
+func TestBlockedFalsePositiveRate(t *testing.T) {
+	// With a single hash function, the false positive rate is the one of a
+	// BloomFilter: 1 - e^(-n / m).
+	expected := 1 - math.Exp(-1000.0/(10*blockBits))
+	if fp := blockedFalsePositiveRate(1000, 10, 1); math.Abs(fp-expected) > 1e-9 {
+		t.Errorf("Expected false positive rate %f, got %f", expected, fp)
+	}
+
+	// The number of blocks is the minimum that keeps the false positive rate.
+	for _, fp := range []float64{0.1, 0.01, 0.0001, 0.000001} {
+		p := newParams(10000, fp, nil)
+		blocks := p.numberOfBlocks(fp)
+		if rate := blockedFalsePositiveRate(p.n, blocks, p.k); rate > fp {
+			t.Errorf("Expected false positive rate below %f, got %f", fp, rate)
+		} else if rate = blockedFalsePositiveRate(p.n, blocks-blocks/64-1, p.k); rate <= fp {
+			t.Errorf("Expected false positive rate above %f with less blocks, got %f", fp, rate)
+		}
+	}
+}
+
+func TestConcurrentBlockedFilter(t *testing.T) {
+	var n, workers = 1000, 8
+	filter := NewBlockedFilter(n*workers, 0.001)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				item := []byte(fmt.Sprintf("item-%d-%d", w, i))
+				filter.Add(item)
+				if !filter.Test(item) {
+					t.Errorf("Expected that filter contains '%s'.", item)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// BenchmarkBlockedFilter function compares the Test function of a BloomFilter
+// and a BlockedFilter with the same false positive rate, testing items that
+// are contained, which check every position, and items that are not, which
+// usually stop at the first positions.
+func BenchmarkBlockedFilter(b *testing.B) {
+	for _, n := range []int{10000, 1000000, 10000000} {
+		standard, blocked := NewFilter(n, 0.0001), NewBlockedFilter(n, 0.0001)
+		filters := []struct {
+			name   string
+			filter membership.Filter
+			m      uint
+		}{
+			{"standard", standard, standard.M()},
+			{"blocked", blocked, blocked.M()},
+		}
+
+		for _, c := range filters {
+			var item = make([]byte, 8)
+			for i := 0; i < n; i++ {
+				binary.BigEndian.PutUint64(item, uint64(i))
+				c.filter.Add(item)
+			}
+
+			for _, contained := range []bool{true, false} {
+				var offset int
+				if !contained {
+					offset = n
+				}
+				b.Run(fmt.Sprintf("%s/n=%d/contained=%t", c.name, n, contained), func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						binary.BigEndian.PutUint64(item, uint64(offset+i*7919%n))
+						c.filter.Test(item)
+					}
+					b.ReportMetric(float64(c.m)/8, "bytes")
+				})
+			}
+		}
+	}
+}
@@ -1,59 +1,100 @@
 package bloomfilter
 
 import (
-	"hash"
-	"hash/fnv"
 	"math"
+
+	"github.com/lucasmenendez/gopsi/internal/hashmix"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
 )
 
+// params struct contains the parameters shared by every kind of filter of the
+// package: the optimal number of positions (m), the optimal number of hash
+// functions (k), the size of the filter (n) and the hash function (hasher).
+type params struct {
+	m uint // number of positions of the filter
+	k uint // number of hashing functions
+	n uint // number of items the filter is sized for
+
+	hasher Hasher // hash function
+}
+
 // BloomFilter struct contains the required parameters to create and use a
 // filter such as the data bitmap (data), the optimal number of bits (m), the
-// optimal number of hash functions (k) and the size of the filter (n). It also
-// contains an instance of the hash function to better performance. Read more
-// about Bloom Filter definition and implementation here:
-// https://en.wikipedia.org/wiki/Bloom_filter.
+// optimal number of hash functions (k), the size of the filter (n) and the
+// hash function (hasher). It has no shared mutable state besides its bitmap,
+// whose bits are set atomically, so it is safe to call Add, Test and
+// TestMultiple concurrently. Read more about Bloom Filter definition and
+// implementation here: https://en.wikipedia.org/wiki/Bloom_filter.
 type BloomFilter struct {
-	data []bool      // filter content
-	m    uint        // number of bits of the filter
-	k    uint        // number of hashing functions
-	n    uint        // number of items into the filter
-	hash hash.Hash64 // hash function seed
+	params
+	data bitset // filter content
+}
+
+// Option type defines a function that sets an optional parameter of a filter
+// during its creation, such as WithHasher.
+type Option func(*params)
+
+// WithHasher function returns an Option that sets the hash function of the
+// filter, FNVHasher by default (read more in Hasher).
+func WithHasher(hasher Hasher) Option {
+	return func(p *params) {
+		if hasher != nil {
+			p.hasher = hasher
+		}
+	}
 }
 
 // NewFilter functions initializes a new BloomFilter with the size and false
 // positive rate provided as argument. Using this arguments, it calculates the
 // optimal number of bits for the number of items to store, and optimal number
-// of hash functions for this size.
-func NewFilter(size int, fp float64) (filter *BloomFilter) {
-	// Initializes the Bloom Filter with the size provided and a initialized
-	// hash function seed.
-	filter = &BloomFilter{
-		n:    uint(size),
-		hash: fnv.New64a(),
-	}
+// of hash functions for this size. The size must be at least 1, and the false
+// positive rate must be into the range (0, 1). The options provided are
+// applied in order, for example, to use a keyed hash function.
+func NewFilter(size int, fp float64, options ...Option) (filter *BloomFilter) {
+	filter = &BloomFilter{params: newParams(size, fp, options)}
 
-	// Calculate the required number of bits of the filter by the data size and
-	// the false positive rate provided.
-	filter.m = filter.numberOfBits(fp)
-	// Initialize the filter data bit map creating a slice of bool to store
+	// Initialize the filter data bit map creating a packed bitset to store
 	// the calculate number of bits (m).
-	filter.data = make([]bool, filter.m)
+	filter.data = newBitset(filter.m)
+	return
+}
+
+// newParams function calculates the parameters of a filter for the size and
+// false positive rate provided, using the default hash function or the one set
+// by the options provided. A size lower than 1 is considered as 1.
+func newParams(size int, fp float64, options []Option) (p params) {
+	if size < 1 {
+		size = 1
+	}
 
-	// Calculate the optimal number of hashes.
-	filter.k = filter.numberOfHashes()
+	// Initializes the parameters with the size provided and the default hash
+	// function, then apply the options provided.
+	p = params{n: uint(size), hasher: FNVHasher()}
+	for _, option := range options {
+		option(&p)
+	}
+
+	// Calculate the required number of bits of the filter by the data size and
+	// the false positive rate provided, and then the optimal number of hashes.
+	// Then, raise the number of bits to the minimum required by the double
+	// hashing, keeping the number of hashes.
+	p.m = p.numberOfBits(fp)
+	p.k = p.numberOfHashes()
+	if minimum := p.minimumBits(fp); p.m < minimum {
+		p.m = minimum
+	}
 	return
 }
 
 // calcHash function generates a splitted 64-bits hash representation of the
 // byte array provided as input. The hash is splitted to allow to create k
-// hashes according to Kirsch-Mitzenmacher optimization, instead of create k
-// single hashes.
-func (f *BloomFilter) calcHash(input []byte) (uint, uint) {
-	defer f.hash.Reset()
-
+// hashes with double hashing (read more in position), instead of create k
+// single hashes. The hash is mixed first (read more in hashmix.Mix64), because the
+// halves of the hashes of similar items, such as FNV-1a ones, are correlated.
+// The filter Hasher is stateless, so it is safe for concurrent use.
+func (p *params) calcHash(input []byte) (uint, uint) {
 	// Create hash of 64 bits from the current item
-	f.hash.Write(input)
-	var hashed uint64 = f.hash.Sum64()
+	var hashed uint64 = hashmix.Mix64(p.hasher.Sum64(input))
 
 	// Split the hashed
 	var a, b uint32 = uint32(hashed >> 32), uint32(hashed)
@@ -61,42 +102,119 @@ func (f *BloomFilter) calcHash(input []byte) (uint, uint) {
 	return uint(a), uint(b)
 }
 
+// position function returns the i-th position of the item with the hash parts
+// provided, following the enhanced double hashing: a + i*b + (i^3 - i) / 6
+// (mod m). The Kirsch-Mitzenmacher combination, a + i*b, only reaches the
+// positions congruent with a modulo the divisors that b shares with m, which
+// increases the false positive rate of the small filters (for example, from
+// 0.0001 to 0.08 for two items). Read more about it in "Bloom Filters in
+// Probabilistic Verification" (Dillinger and Manolios, 2004). Every filter
+// with the same parameters sets the same positions for an item, so their
+// bitmaps can be combined (read more in BloomFilter.Union) or encoded (read
+// more in BloomFilter.MarshalBinary).
+func (p *params) position(a, b, i uint) uint {
+	return (a + i*b + (i*i*i-i)/6) % p.m
+}
+
 // numberOfBits function calculates the optimal number of bits to store the
 // current size of the filter (n: number of items) with the provided false
 // positive rate (fp).
-func (f *BloomFilter) numberOfBits(fp float64) uint {
+func (p *params) numberOfBits(fp float64) uint {
 	// Calculate the number of bits (m) of the filter by the it size (n) and the
 	// false positive rate provided as argument according to the following
 	// formula: m = -1 * (n * ln(fp)) / ln(2)^2
-	return uint(-1 * float64(f.n) * math.Logb(fp) / math.Pow(math.Logb(2), 2))
+	var m float64 = math.Ceil(-1 * float64(p.n) * math.Log(fp) / math.Pow(math.Ln2, 2))
+	if m < 1 {
+		return 1
+	}
+	return uint(m)
+}
+
+// minimumBits function calculates the minimum number of bits of a filter with
+// the current size (n) and the provided false positive rate (fp), according to
+// the double hashing used to calculate the positions of the items (read more
+// in position). The positions of an item only depend on its hash parts modulo
+// m, so there are only m^2 different sets of positions, and an item that is
+// not into the filter shares the positions of one of the n items of the
+// filter with a probability of n / m^2, which exceeds the false positive rate
+// of the small filters. This probability is kept below a quarter of the false
+// positive rate: m = sqrt(4 * n / fp).
+func (p *params) minimumBits(fp float64) uint {
+	return uint(math.Ceil(math.Sqrt(4 * float64(p.n) / fp)))
 }
 
 // numberOfHashes function calculates the optimal number of hashes for the
 // current filter size (n: number of items) and the current number of bits (m).
-func (f *BloomFilter) numberOfHashes() uint {
+func (p *params) numberOfHashes() uint {
 	// Calculate the number of hash functions (k) of the filter by the number of
 	// bits (m) and the size of the filter (n), according to the following
 	// formula: k = (m / n) * ln(2)
-	return uint(math.Ceil(math.Logb(2) * float64(f.m) / float64(f.n)))
+	var k float64 = math.Round(math.Ln2 * float64(p.m) / float64(p.n))
+	if k < 1 {
+		return 1
+	}
+	return uint(k)
+}
+
+// M function returns the number of positions (bits or counters) of the filter.
+func (p *params) M() uint {
+	return p.m
+}
+
+// K function returns the number of hash functions of the filter.
+func (p *params) K() uint {
+	return p.k
+}
+
+// N function returns the number of items that the filter was sized for.
+func (p *params) N() uint {
+	return p.n
+}
+
+// Hasher function returns the hash function of the filter.
+func (p *params) Hasher() Hasher {
+	return p.hasher
+}
+
+// FillRatio function returns the ratio of bits of the filter that are set.
+func (f *BloomFilter) FillRatio() float64 {
+	return float64(f.data.count()) / float64(f.m)
+}
+
+// EstimatedFalsePositiveRate function returns the false positive rate of the
+// filter according to the bits actually set instead of the number of items
+// that it was sized for, following the formula: fp = FillRatio^k. It
+// approaches the false positive rate provided to NewFilter as the filter is
+// filled with the number of items that it was sized for, and exceeds it if
+// the filter is filled with more items.
+func (f *BloomFilter) EstimatedFalsePositiveRate() float64 {
+	return math.Pow(f.FillRatio(), float64(f.k))
 }
 
 // Add function allows to user to insert one (or more) items to the created
 // filter. It calculates the position of each input with the number of hashes to
-// mark as contained.
-func (f *BloomFilter) Add(items ...[]byte) {
+// mark as contained. It never fails, it returns an error to implement
+// membership.Filter.
+func (f *BloomFilter) Add(items ...[]byte) error {
 	for _, item := range items {
 		// For each item provided, calculate both hash parts to generate k hash
-		// functions according to the Kirsch-Mitzenmacher optimization
-		// (https://www.eecs.harvard.edu/~michaelm/postscripts/tr-02-05.pdf).
+		// functions with double hashing (read more in position).
 		var a, b uint = f.calcHash(item)
 
 		// Set to 1 (true) every bit map position calculates with the hash parts
 		// generated.
 		for i := uint(0); i < f.k; i++ {
-			var index uint = (a + i*b) % f.m
-			f.data[index] = true
+			f.data.set(f.position(a, b, i))
 		}
 	}
+	return nil
+}
+
+// Remove function returns membership.ErrUnsupported, because the bits of a
+// Bloom filter can be shared by many items, so they cannot be unset. Read more
+// in CountingFilter.
+func (f *BloomFilter) Remove(items ...[]byte) error {
+	return membership.ErrUnsupported
 }
 
 // Test function allows to user to check if the current filter has already an
@@ -112,8 +230,7 @@ func (f *BloomFilter) Test(item []byte) bool {
 	// (0) into the bitmap (f.data), it does not contains the item. If every
 	// byte position are true (1), the bitma probably contains the item.
 	for i := uint(0); i < f.k; i++ {
-		var index uint = (a + b*i) % f.m
-		if !f.data[index] {
+		if !f.data.test(f.position(a, b, i)) {
 			return false
 		}
 	}
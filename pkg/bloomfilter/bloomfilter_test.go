@@ -1,6 +1,16 @@
 package bloomfilter
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/internal/hashmix"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
 
 func TestFilter(t *testing.T) {
 	items := [][]byte{
@@ -31,4 +41,142 @@ func TestFilter(t *testing.T) {
 	if filter.Test(input) {
 		t.Errorf("Expected that filter not contains '%s'.", input)
 	}
+
+	if err := filter.Remove(items[0]); !errors.Is(err, membership.ErrUnsupported) {
+		t.Errorf("Expected %v, got %v", membership.ErrUnsupported, err)
+	}
+}
+
+func TestFilterParams(t *testing.T) {
+	var cases = []struct {
+		n    int
+		fp   float64
+		m, k uint
+	}{
+		{1000, 0.01, 9586, 7},
+		{1000, 0.0001, 19171, 13},
+		{1, 0.5, 3, 1},
+		{0, 0.01, 20, 7},
+	}
+	for _, c := range cases {
+		filter := NewFilter(c.n, c.fp)
+		if filter.M() != c.m {
+			t.Errorf("Expected m = %d for n = %d and fp = %f, got %d", c.m, c.n, c.fp, filter.M())
+		} else if filter.K() != c.k {
+			t.Errorf("Expected k = %d for n = %d and fp = %f, got %d", c.k, c.n, c.fp, filter.K())
+		}
+	}
+}
+
+func TestSmallFilterFalsePositiveRate(t *testing.T) {
+	// Measure the false positive rate of many small filters with sequential
+	// items, whose hashes are similar, and check that it does not exceed the
+	// false positive rate provided for every size.
+	var fp, trials = 0.001, 200000
+	for _, n := range []int{1, 2, 10, 100} {
+		var id uint64
+		var positives, tested int
+		for tested < trials {
+			filter := NewFilter(n, fp)
+			for i := 0; i < n; i++ {
+				id++
+				filter.Add([]byte(fmt.Sprintf("item-%d", id)))
+			}
+			for i := 0; i < 100; i++ {
+				id++
+				if filter.Test([]byte(fmt.Sprintf("item-%d", id))) {
+					positives++
+				}
+				tested++
+			}
+		}
+		if actual := float64(positives) / float64(tested); actual > fp*2 {
+			t.Errorf("Expected false positive rate close to %f for n = %d, got %f", fp, n, actual)
+		}
+	}
+}
+
+func TestEstimatedFalsePositiveRate(t *testing.T) {
+	var n, fp = 10000, 0.01
+	filter := NewFilter(n, fp)
+	if filter.N() != uint(n) {
+		t.Errorf("Expected n = %d, got %d", n, filter.N())
+	} else if filter.FillRatio() != 0 || filter.EstimatedFalsePositiveRate() != 0 {
+		t.Errorf("Expected empty filter, got fill ratio %f", filter.FillRatio())
+	}
+
+	for i := 0; i < n; i++ {
+		filter.Add([]byte(fmt.Sprintf("item-%d", i)))
+	}
+	// The expected fill ratio is 1 - e^(-kn/m), close to 0.5 for an optimal
+	// filter.
+	expected := 1 - math.Exp(-float64(filter.K())*float64(n)/float64(filter.M()))
+	if ratio := filter.FillRatio(); math.Abs(ratio-expected) > 0.01 {
+		t.Errorf("Expected fill ratio close to %f, got %f", expected, ratio)
+	}
+	estimated := filter.EstimatedFalsePositiveRate()
+	if estimated < fp/2 || estimated > fp*2 {
+		t.Errorf("Expected estimated false positive rate close to %f, got %f", fp, estimated)
+	}
+
+	// Check the actual false positive rate with items not added.
+	var positives int
+	for i := 0; i < n; i++ {
+		if filter.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(n); actual > fp*2 {
+		t.Errorf("Expected false positive rate close to %f, got %f", fp, actual)
+	}
+}
+
+func TestCalcHash(t *testing.T) {
+	filter := NewFilter(1, 0.01)
+	for _, input := range []string{"", "a", "hello world"} {
+		hash := fnv.New64a()
+		hash.Write([]byte(input))
+		var hashed uint64 = hashmix.Mix64(hash.Sum64())
+
+		a, b := filter.calcHash([]byte(input))
+		if a != uint(uint32(hashed>>32)) || b != uint(uint32(hashed)) {
+			t.Errorf("Expected mixed FNV-1a hash %x for '%s', got %x %x", hashed, input, a, b)
+		}
+	}
+}
+
+func TestConcurrentFilter(t *testing.T) {
+	var n, workers = 1000, 8
+	filter := NewFilter(n*workers, 0.001)
+
+	// Add different items from every worker while the other ones test them.
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				item := []byte(fmt.Sprintf("item-%d-%d", w, i))
+				filter.Add(item)
+				if !filter.Test(item) {
+					t.Errorf("Expected that filter contains '%s'.", item)
+				}
+				filter.Test([]byte(fmt.Sprintf("item-%d-%d", (w+1)%workers, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Every item must be into the filter after the concurrent calls.
+	for w := 0; w < workers; w++ {
+		items := make([][]byte, n)
+		for i := range items {
+			items[i] = []byte(fmt.Sprintf("item-%d-%d", w, i))
+		}
+		for i, result := range filter.TestMultiple(items...) {
+			if !result {
+				t.Errorf("Expected that filter contains '%s'.", items[i])
+			}
+		}
+	}
 }
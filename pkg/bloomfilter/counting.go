@@ -0,0 +1,141 @@
+package bloomfilter
+
+import "sync/atomic"
+
+// Layout of the counters of a CountingFilter: 4 bits per counter, 16 counters
+// per 64-bit word.
+const (
+	counterBits  = 4
+	counterMax   = 1<<counterBits - 1
+	counterMask  = uint64(counterMax)
+	wordCounters = wordSize / counterBits
+)
+
+// counters type implements a packed array of 4-bit counters. Its words are
+// accessed atomically, so it is safe for concurrent use. The counters saturate
+// at their maximum value, and a saturated counter is never decremented, which
+// avoids false negatives at the cost of keeping its position set forever.
+type counters []uint64
+
+// newCounters function returns counters with enough words to store the number
+// of counters provided.
+func newCounters(size uint) counters {
+	return make(counters, (size+wordCounters-1)/wordCounters)
+}
+
+// get function returns the value of the counter of the index provided.
+func (c counters) get(index uint) uint64 {
+	var shift uint = (index % wordCounters) * counterBits
+	return atomic.LoadUint64(&c[index/wordCounters]) >> shift & counterMask
+}
+
+// update function adds the delta provided (1 or -1) to the counter of the
+// index provided, retrying the atomic compare-and-swap of its word until no
+// other goroutine has modified it in the meantime. It does nothing if the
+// counter is saturated or if it is empty and the delta is negative.
+func (c counters) update(index uint, delta int) {
+	var word *uint64 = &c[index/wordCounters]
+	var shift uint = (index % wordCounters) * counterBits
+	for {
+		var old uint64 = atomic.LoadUint64(word)
+		var value uint64 = old >> shift & counterMask
+		if value == counterMax || (value == 0 && delta < 0) {
+			return
+		}
+
+		var updated uint64 = old + 1<<shift
+		if delta < 0 {
+			updated = old - 1<<shift
+		}
+		if atomic.CompareAndSwapUint64(word, old, updated) {
+			return
+		}
+	}
+}
+
+// CountingFilter struct contains the required parameters to create and use a
+// counting Bloom filter, that replaces every bit of the filter with a 4-bit
+// counter to support removing items, at the cost of four times the memory of a
+// BloomFilter with the same parameters. It is safe to call Add, Remove and
+// Test concurrently, but removing an item that was not added (even if Test
+// returns true for it, as a false positive) can remove other items from the
+// filter. Read more about counting Bloom filters here:
+// https://en.wikipedia.org/wiki/Counting_Bloom_filter.
+type CountingFilter struct {
+	params
+	data counters // filter content
+}
+
+// NewCountingFilter function initializes a new CountingFilter with the size,
+// false positive rate and options provided, calculating its parameters as
+// NewFilter does.
+func NewCountingFilter(size int, fp float64, options ...Option) *CountingFilter {
+	var p params = newParams(size, fp, options)
+	return &CountingFilter{params: p, data: newCounters(p.m)}
+}
+
+// Add function inserts one (or more) items into the filter, incrementing the
+// counters of the positions of each item. It never fails, it returns an error
+// to implement membership.Filter.
+func (f *CountingFilter) Add(items ...[]byte) error {
+	for _, item := range items {
+		var a, b uint = f.calcHash(item)
+		for i := uint(0); i < f.k; i++ {
+			f.data.update(f.position(a, b, i), 1)
+		}
+	}
+	return nil
+}
+
+// Remove function deletes one (or more) items from the filter, decrementing
+// the counters of the positions of each item. The items that are not into the
+// filter according to Test are skipped. Each item must be removed as many
+// times as it was added to delete it from the filter. It never fails, it
+// returns an error to implement membership.Filter.
+func (f *CountingFilter) Remove(items ...[]byte) error {
+	for _, item := range items {
+		if !f.Test(item) {
+			continue
+		}
+
+		var a, b uint = f.calcHash(item)
+		for i := uint(0); i < f.k; i++ {
+			f.data.update(f.position(a, b, i), -1)
+		}
+	}
+	return nil
+}
+
+// Test function checks if the filter contains the item provided, which is
+// true if every counter of its positions is greater than zero.
+func (f *CountingFilter) Test(item []byte) bool {
+	var a, b uint = f.calcHash(item)
+	for i := uint(0); i < f.k; i++ {
+		if f.data.get(f.position(a, b, i)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestMultiple function tests multiple items at the same time, using the
+// CountingFilter.Test function.
+func (f *CountingFilter) TestMultiple(items ...[]byte) (results []bool) {
+	results = make([]bool, len(items))
+	for i, item := range items {
+		results[i] = f.Test(item)
+	}
+	return
+}
+
+// FillRatio function returns the ratio of counters of the filter that are not
+// zero.
+func (f *CountingFilter) FillRatio() float64 {
+	var set uint
+	for i := uint(0); i < f.m; i++ {
+		if f.data.get(i) != 0 {
+			set++
+		}
+	}
+	return float64(set) / float64(f.m)
+}
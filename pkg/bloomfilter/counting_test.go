@@ -0,0 +1,116 @@
+package bloomfilter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	data := newCounters(20)
+	if len(data) != 2 {
+		t.Errorf("Expected 2 words, got %d", len(data))
+	}
+
+	data.update(15, 1)
+	data.update(15, 1)
+	data.update(16, 1)
+	if data.get(15) != 2 || data.get(16) != 1 || data.get(14) != 0 {
+		t.Errorf("Expected counters 0, 2, 1, got %d, %d, %d", data.get(14), data.get(15), data.get(16))
+	}
+
+	// Empty counters are not decremented.
+	data.update(14, -1)
+	data.update(16, -1)
+	data.update(16, -1)
+	if data.get(14) != 0 || data.get(16) != 0 || data.get(15) != 2 {
+		t.Errorf("Expected counters 0, 2, 0, got %d, %d, %d", data.get(14), data.get(15), data.get(16))
+	}
+
+	// Saturated counters are never decremented.
+	for i := 0; i < counterMax+5; i++ {
+		data.update(3, 1)
+	}
+	data.update(3, -1)
+	if data.get(3) != counterMax || data.get(2) != 0 || data.get(4) != 0 {
+		t.Errorf("Expected saturated counter, got %d", data.get(3))
+	}
+}
+
+func TestCountingFilter(t *testing.T) {
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	filter := NewCountingFilter(len(items), 0.001)
+	reference := NewFilter(len(items), 0.001)
+	if filter.M() != reference.M() || filter.K() != reference.K() {
+		t.Fatalf("Expected m, k = %d, %d, got %d, %d", reference.M(), reference.K(), filter.M(), filter.K())
+	}
+
+	filter.Add(items...)
+	for i, result := range filter.TestMultiple(items...) {
+		if !result {
+			t.Errorf("Expected that filter contains '%s'.", items[i])
+		}
+	}
+
+	// Remove the first half of the items, that must not be into the filter
+	// (besides false positives) while the other half must remain.
+	filter.Remove(items[:500]...)
+	var positives int
+	for _, result := range filter.TestMultiple(items[:500]...) {
+		if result {
+			positives++
+		}
+	}
+	if positives > 5 {
+		t.Errorf("Expected removed items, got %d positives", positives)
+	}
+	for i, result := range filter.TestMultiple(items[500:]...) {
+		if !result {
+			t.Errorf("Expected that filter contains '%s'.", items[500+i])
+		}
+	}
+
+	// Items added twice must be removed twice.
+	item := []byte("twice")
+	filter.Add(item, item)
+	filter.Remove(item)
+	if !filter.Test(item) {
+		t.Errorf("Expected that filter contains '%s'.", item)
+	}
+
+	filter.Remove(items[500:]...)
+	filter.Remove(item)
+	if ratio := filter.FillRatio(); ratio != 0 {
+		t.Errorf("Expected empty filter, got fill ratio %f", ratio)
+	}
+}
+
+func TestConcurrentCountingFilter(t *testing.T) {
+	var n, workers = 500, 8
+	filter := NewCountingFilter(n*workers, 0.001)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				item := []byte(fmt.Sprintf("item-%d-%d", w, i))
+				filter.Add(item)
+				if !filter.Test(item) {
+					t.Errorf("Expected that filter contains '%s'.", item)
+				}
+				filter.Remove(item)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if ratio := filter.FillRatio(); ratio != 0 {
+		t.Errorf("Expected empty filter, got fill ratio %f", ratio)
+	}
+}
@@ -0,0 +1,448 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sync/atomic"
+)
+
+// Version contains the current version of the binary format of the filters.
+// The version 2 sets the positions of the items with the enhanced double
+// hashing of a mixed hash (read more in params.position), so the bitmaps of
+// the version 1 are not readable.
+const Version byte = 2
+
+// Sizes of the binary format: the header contains the magic string, the
+// version, the hash algorithm and the parameters m, k and n of the filter, and
+// the checksum is the CRC-32 (IEEE) of the header and the bitmap.
+const (
+	headerSize   = 4 + 1 + 1 + 3*8
+	checksumSize = 4
+)
+
+// maxHashes contains the maximum number of hash functions accepted when a
+// filter is decoded, much larger than the optimal one for any false positive
+// rate, to avoid that a crafted filter makes every operation too slow.
+const maxHashes = 256
+
+// scalableHeaderSize contains the size of the header of the binary format of
+// the scalable filters, that contains the magic string, the version, the hash
+// algorithm, the number of filters, the initial size, the false positive rate,
+// the number of items of the last filter and the total number of items.
+const scalableHeaderSize = 4 + 1 + 1 + 1 + 4*8
+
+// golombHeaderSize contains the size of the header of the binary format of the
+// Golomb-coded sets, that contains the magic string, the version, the hash
+// algorithm, the Golomb-Rice parameter, M, the number of items and the number
+// of values.
+const golombHeaderSize = 4 + 1 + 1 + 1 + 3*8
+
+// Magic strings of the binary format of each kind of filter.
+var (
+	magic         = []byte("GPBF")
+	countingMagic = []byte("GPCF")
+	scalableMagic = []byte("GPSF")
+	blockedMagic  = []byte("GPBB")
+	golombMagic   = []byte("GPGC")
+)
+
+var (
+	// ErrFormat is returned when the input is not a valid encoded filter.
+	ErrFormat = errors.New("bloomfilter: invalid format")
+	// ErrVersion is returned when the input version is not supported.
+	ErrVersion = errors.New("bloomfilter: unsupported version")
+	// ErrChecksum is returned when the checksum of the input does not match.
+	ErrChecksum = errors.New("bloomfilter: checksum mismatch")
+	// ErrHasher is returned when the hash algorithm of the input does not
+	// match the filter Hasher.
+	ErrHasher = errors.New("bloomfilter: hash algorithm mismatch")
+)
+
+// MarshalBinary function encodes the filter into a versioned binary format,
+// implementing encoding.BinaryMarshaler. It starts with a header that contains
+// the magic string "GPBF", the format version, the hash algorithm and the
+// parameters m, k and n as big-endian 64-bit integers, followed by the bitmap
+// as big-endian 64-bit words and the CRC-32 checksum of everything before it.
+// The key of a keyed Hasher is not encoded, so it must be shared apart.
+func (f *BloomFilter) MarshalBinary() ([]byte, error) {
+	return marshal(magic, f.params, f.data), nil
+}
+
+// UnmarshalBinary function decodes a filter encoded by MarshalBinary into the
+// current one, implementing encoding.BinaryUnmarshaler. It returns an error if
+// the input is not valid or its checksum does not match. The current filter
+// Hasher is kept if it uses the hash algorithm of the input, otherwise the
+// unkeyed algorithms are instanced by default and the keyed ones return
+// ErrHasher, so the filters that use a keyed Hasher must be decoded with
+// UnmarshalFilter and WithHasher. It is not safe to call it concurrently with
+// any other function of the filter.
+func (f *BloomFilter) UnmarshalBinary(data []byte) error {
+	p, words, err := unmarshal(magic, data, f.hasher, 1)
+	if err != nil {
+		return err
+	}
+
+	f.params, f.data = p, words
+	return nil
+}
+
+// UnmarshalFilter function decodes a filter encoded by MarshalBinary, applying
+// the options provided before decoding it, for example, to provide the keyed
+// Hasher used to encode it.
+func UnmarshalFilter(data []byte, options ...Option) (*BloomFilter, error) {
+	var filter = &BloomFilter{}
+	for _, option := range options {
+		option(&filter.params)
+	}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// MarshalBinary function encodes the filter into the binary format of
+// BloomFilter.MarshalBinary, but starting with the magic string "GPCF" and
+// followed by its counters instead of the bitmap.
+func (f *CountingFilter) MarshalBinary() ([]byte, error) {
+	return marshal(countingMagic, f.params, f.data), nil
+}
+
+// UnmarshalBinary function decodes a filter encoded by
+// CountingFilter.MarshalBinary into the current one, as
+// BloomFilter.UnmarshalBinary does.
+func (f *CountingFilter) UnmarshalBinary(data []byte) error {
+	p, words, err := unmarshal(countingMagic, data, f.hasher, counterBits)
+	if err != nil {
+		return err
+	}
+
+	f.params, f.data = p, words
+	return nil
+}
+
+// UnmarshalCountingFilter function decodes a filter encoded by
+// CountingFilter.MarshalBinary, applying the options provided before decoding
+// it, as UnmarshalFilter does.
+func UnmarshalCountingFilter(data []byte, options ...Option) (*CountingFilter, error) {
+	var filter = &CountingFilter{}
+	for _, option := range options {
+		option(&filter.params)
+	}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// MarshalBinary function encodes the filter into the binary format of
+// BloomFilter.MarshalBinary, but starting with the magic string "GPBB".
+func (f *BlockedFilter) MarshalBinary() ([]byte, error) {
+	return marshal(blockedMagic, f.params, f.data), nil
+}
+
+// UnmarshalBinary function decodes a filter encoded by
+// BlockedFilter.MarshalBinary into the current one, as
+// BloomFilter.UnmarshalBinary does. The number of bits must be a multiple of
+// the size of a block.
+func (f *BlockedFilter) UnmarshalBinary(data []byte) error {
+	p, words, err := unmarshal(blockedMagic, data, f.hasher, 1)
+	if err != nil {
+		return err
+	} else if p.m%blockBits != 0 {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	// Copy the words into aligned blocks.
+	var blocks uint = p.m / blockBits
+	f.data = newBlocks(blocks)
+	copy(f.data, words)
+	f.params, f.blocks = p, blocks
+	return nil
+}
+
+// UnmarshalBlockedFilter function decodes a filter encoded by
+// BlockedFilter.MarshalBinary, applying the options provided before decoding
+// it, as UnmarshalFilter does.
+func UnmarshalBlockedFilter(data []byte, options ...Option) (*BlockedFilter, error) {
+	var filter = &BlockedFilter{}
+	for _, option := range options {
+		option(&filter.params)
+	}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// MarshalBinary function encodes the filter into a versioned binary format,
+// implementing encoding.BinaryMarshaler. It starts with a header that contains
+// the magic string "GPSF", the format version, the hash algorithm and the
+// number of filters, followed by the initial size, the false positive rate
+// (IEEE 754), the number of items of the last filter and the total number of
+// items as big-endian 64-bit integers. Then, it contains every filter encoded
+// by BloomFilter.MarshalBinary, prefixed by its length as a big-endian 32-bit
+// integer, and the CRC-32 checksum of everything before it.
+func (f *ScalableFilter) MarshalBinary() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var data []byte = make([]byte, scalableHeaderSize)
+	copy(data, scalableMagic)
+	data[4], data[5], data[6] = Version, byte(f.hasher.Algorithm()), byte(len(f.filters))
+	binary.BigEndian.PutUint64(data[7:], uint64(f.size))
+	binary.BigEndian.PutUint64(data[15:], math.Float64bits(f.fp))
+	binary.BigEndian.PutUint64(data[23:], uint64(f.last))
+	binary.BigEndian.PutUint64(data[31:], uint64(f.count))
+
+	var length [4]byte
+	for _, filter := range f.filters {
+		encoded, _ := filter.MarshalBinary()
+		binary.BigEndian.PutUint32(length[:], uint32(len(encoded)))
+		data = append(append(data, length[:]...), encoded...)
+	}
+
+	var checksum [checksumSize]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(data))
+	return append(data, checksum[:]...), nil
+}
+
+// UnmarshalBinary function decodes a filter encoded by
+// ScalableFilter.MarshalBinary into the current one, as
+// BloomFilter.UnmarshalBinary does. Every filter must have the size and the
+// false positive rate of its position.
+func (f *ScalableFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < scalableHeaderSize+checksumSize {
+		return fmt.Errorf("%w: too short", ErrFormat)
+	} else if string(data[:4]) != string(scalableMagic) {
+		return fmt.Errorf("%w: bad magic", ErrFormat)
+	} else if data[4] != Version {
+		return fmt.Errorf("%w: %d", ErrVersion, data[4])
+	}
+
+	var body []byte = data[:len(data)-checksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return ErrChecksum
+	}
+
+	var size, last, count = binary.BigEndian.Uint64(data[7:]), binary.BigEndian.Uint64(data[23:]), binary.BigEndian.Uint64(data[31:])
+	var fp float64 = math.Float64frombits(binary.BigEndian.Uint64(data[15:]))
+	var slices int = int(data[6])
+	if size == 0 || size > math.MaxUint32 || !(fp > 0 && fp < 1) || slices == 0 || slices > maxSlices {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Decode every filter with the current hasher, checking that it has the
+	// expected parameters according to its position.
+	var decoded = &ScalableFilter{size: uint(size), fp: fp, hasher: f.hasher}
+	var rest []byte = body[scalableHeaderSize:]
+	for i := 0; i < slices; i++ {
+		if len(rest) < 4 || uint64(len(rest)-4) < uint64(binary.BigEndian.Uint32(rest)) {
+			return fmt.Errorf("%w: truncated filter", ErrFormat)
+		}
+
+		var length uint32 = binary.BigEndian.Uint32(rest)
+		var filter = &BloomFilter{params: params{hasher: decoded.hasher}}
+		if err := filter.UnmarshalBinary(rest[4 : 4+length]); err != nil {
+			return err
+		}
+		rest = rest[4+length:]
+
+		var sliceSize, sliceFP = decoded.slice(i)
+		var expected params = newParams(int(sliceSize), sliceFP, nil)
+		if filter.N() != expected.n || filter.M() != expected.m || filter.K() != expected.k || filter.hasher.Algorithm() != HashAlgorithm(data[5]) {
+			return fmt.Errorf("%w: invalid filter %d", ErrFormat, i)
+		}
+		decoded.filters, decoded.hasher = append(decoded.filters, filter), filter.hasher
+	}
+	if len(rest) != 0 || last > uint64(decoded.filters[slices-1].N()) || count < last {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	f.filters, f.size, f.fp, f.hasher = decoded.filters, decoded.size, decoded.fp, decoded.hasher
+	f.last, f.count = uint(last), uint(count)
+	return nil
+}
+
+// UnmarshalScalableFilter function decodes a filter encoded by
+// ScalableFilter.MarshalBinary, applying the options provided before decoding
+// it, as UnmarshalFilter does.
+func UnmarshalScalableFilter(data []byte, options ...Option) (*ScalableFilter, error) {
+	var p params
+	for _, option := range options {
+		option(&p)
+	}
+
+	var filter = &ScalableFilter{hasher: p.hasher}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// MarshalBinary function encodes the set into a versioned binary format,
+// implementing encoding.BinaryMarshaler. It starts with a header that contains
+// the magic string "GPGC", the format version, the hash algorithm and the
+// Golomb-Rice parameter, followed by M, the number of items and the number of
+// values as big-endian 64-bit integers. Then, it contains the Golomb-Rice coded
+// values and the CRC-32 checksum of everything before it. The index is not
+// encoded, it is calculated again when the set is decoded.
+func (s *GolombSet) MarshalBinary() ([]byte, error) {
+	var data []byte = make([]byte, golombHeaderSize+len(s.data)+checksumSize)
+	copy(data, golombMagic)
+	data[4], data[5], data[6] = Version, byte(s.hasher.Algorithm()), byte(s.p)
+	binary.BigEndian.PutUint64(data[7:], s.m)
+	binary.BigEndian.PutUint64(data[15:], uint64(s.n))
+	binary.BigEndian.PutUint64(data[23:], uint64(s.count))
+	copy(data[golombHeaderSize:], s.data)
+
+	var body []byte = data[:len(data)-checksumSize]
+	binary.BigEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+	return data, nil
+}
+
+// UnmarshalBinary function decodes a set encoded by GolombSet.MarshalBinary
+// into the current one, as BloomFilter.UnmarshalBinary does. Every value is
+// decoded to check that they are sorted, different and into the range of the
+// set, and to calculate the index.
+func (s *GolombSet) UnmarshalBinary(data []byte) error {
+	if len(data) < golombHeaderSize+checksumSize {
+		return fmt.Errorf("%w: too short", ErrFormat)
+	} else if string(data[:4]) != string(golombMagic) {
+		return fmt.Errorf("%w: bad magic", ErrFormat)
+	} else if data[4] != Version {
+		return fmt.Errorf("%w: %d", ErrVersion, data[4])
+	}
+
+	var body []byte = data[:len(data)-checksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return ErrChecksum
+	}
+
+	hasher, err := decodeHasher(s.hasher, HashAlgorithm(data[5]))
+	if err != nil {
+		return err
+	}
+
+	// Check the parameters, keeping n * M into 63 bits and requiring at least
+	// one bit per value, and decode the values.
+	var p, m, n, count = uint64(data[6]), binary.BigEndian.Uint64(data[7:]), binary.BigEndian.Uint64(data[15:]), binary.BigEndian.Uint64(data[23:])
+	var encoded []byte = body[golombHeaderSize:]
+	if p >= wordSize || m == 0 || n == 0 || n > math.MaxInt64/m || count > n || count > uint64(len(encoded))*8 {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	var decoded = &GolombSet{n: uint(n), count: uint(count), m: m, p: uint(p), hasher: hasher}
+	decoded.data = append([]byte{}, encoded...)
+	index, ok := decoded.decodeIndex()
+	if !ok {
+		return fmt.Errorf("%w: invalid values", ErrFormat)
+	}
+
+	s.n, s.count, s.m, s.p, s.hasher = decoded.n, decoded.count, decoded.m, decoded.p, hasher
+	s.data, s.index = decoded.data, index
+	return nil
+}
+
+// UnmarshalGolombSet function decodes a set encoded by GolombSet.MarshalBinary,
+// applying the options provided before decoding it, as UnmarshalFilter does.
+func UnmarshalGolombSet(data []byte, options ...Option) (*GolombSet, error) {
+	var p params
+	for _, option := range options {
+		option(&p)
+	}
+
+	var set = &GolombSet{hasher: p.hasher}
+	if err := set.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// marshal function encodes the parameters and the words provided into the
+// binary format, starting with the magic string provided.
+func marshal(magic []byte, p params, words []uint64) []byte {
+	var data []byte = make([]byte, headerSize+len(words)*8+checksumSize)
+	copy(data, magic)
+	data[4], data[5] = Version, byte(p.hasher.Algorithm())
+	binary.BigEndian.PutUint64(data[6:], uint64(p.m))
+	binary.BigEndian.PutUint64(data[14:], uint64(p.k))
+	binary.BigEndian.PutUint64(data[22:], uint64(p.n))
+
+	for i := range words {
+		binary.BigEndian.PutUint64(data[headerSize+i*8:], atomic.LoadUint64(&words[i]))
+	}
+	var body []byte = data[:len(data)-checksumSize]
+	binary.BigEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+	return data
+}
+
+// decodeHasher function returns the hasher provided if it uses the hash
+// algorithm provided, otherwise it instances the unkeyed algorithms by default
+// and returns ErrHasher for the keyed ones, whose key is not encoded.
+func decodeHasher(hasher Hasher, algorithm HashAlgorithm) (Hasher, error) {
+	if hasher != nil && hasher.Algorithm() == algorithm {
+		return hasher, nil
+	}
+
+	switch algorithm {
+	case FNV1a:
+		return FNVHasher(), nil
+	case SHA256:
+		return SHA256Hasher(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrHasher, algorithm)
+}
+
+// unmarshal function decodes the parameters and the words of the binary format
+// provided, checking that it starts with the magic string provided and that
+// the words can store m positions of the number of bits provided. It keeps the
+// hasher provided if it uses the hash algorithm of the input.
+func unmarshal(magic, data []byte, hasher Hasher, positionBits uint64) (params, []uint64, error) {
+	if len(data) < headerSize+checksumSize {
+		return params{}, nil, fmt.Errorf("%w: too short", ErrFormat)
+	} else if string(data[:4]) != string(magic) {
+		return params{}, nil, fmt.Errorf("%w: bad magic", ErrFormat)
+	} else if data[4] != Version {
+		return params{}, nil, fmt.Errorf("%w: %d", ErrVersion, data[4])
+	}
+
+	var body []byte = data[:len(data)-checksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return params{}, nil, ErrChecksum
+	}
+
+	hasher, err := decodeHasher(hasher, HashAlgorithm(data[5]))
+	if err != nil {
+		return params{}, nil, err
+	}
+
+	// Check the parameters against the size of the words, that must be the
+	// ones required to store m positions without any bit set beyond them.
+	var m, k, n uint64 = binary.BigEndian.Uint64(data[6:]), binary.BigEndian.Uint64(data[14:]), binary.BigEndian.Uint64(data[22:])
+	// The number of positions is checked against the ones that fit into the
+	// words before any arithmetic with it, so crafted values close to 2^64 do
+	// not overflow.
+	var perWord uint64 = wordSize / positionBits
+	var encoded []byte = body[headerSize:]
+	if m == 0 || k == 0 || k > maxHashes || n == 0 || len(encoded)%8 != 0 || m > uint64(len(encoded))/8*perWord {
+		return params{}, nil, fmt.Errorf("%w: invalid parameters", ErrFormat)
+	} else if uint64(len(encoded)/8) != m/perWord+(m%perWord+perWord-1)/perWord {
+		return params{}, nil, fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	var words []uint64 = make([]uint64, len(encoded)/8)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint64(encoded[i*8:])
+	}
+	if rest := m % perWord * positionBits; rest != 0 && words[len(words)-1]>>rest != 0 {
+		return params{}, nil, fmt.Errorf("%w: bits out of range", ErrFormat)
+	}
+
+	return params{m: uint(m), k: uint(k), n: uint(n), hasher: hasher}, words, nil
+}
@@ -0,0 +1,338 @@
+package bloomfilter
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*BloomFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*BloomFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*CountingFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*ScalableFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*BlockedFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*GolombSet)(nil)
+	_ membership.Filter          = (*BloomFilter)(nil)
+	_ membership.Filter          = (*CountingFilter)(nil)
+	_ membership.Filter          = (*BlockedFilter)(nil)
+	_ membership.Filter          = (*GolombSet)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	items := make([][]byte, 100)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	for _, hasher := range []Hasher{FNVHasher(), SHA256Hasher(), DeriveSipHasher([]byte("secret"))} {
+		filter := NewFilter(len(items), 0.001, WithHasher(hasher))
+		filter.Add(items...)
+		data, err := filter.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Expected nil, got %s", err)
+		} else if len(data) != headerSize+len(filter.data)*8+checksumSize {
+			t.Fatalf("Expected %d bytes, got %d", headerSize+len(filter.data)*8+checksumSize, len(data))
+		}
+
+		decoded, err := UnmarshalFilter(data, WithHasher(hasher))
+		if err != nil {
+			t.Fatalf("Expected nil, got %s", err)
+		} else if decoded.M() != filter.M() || decoded.K() != filter.K() || decoded.N() != filter.N() {
+			t.Fatalf("Expected m, k, n = %d, %d, %d, got %d, %d, %d", filter.M(), filter.K(), filter.N(), decoded.M(), decoded.K(), decoded.N())
+		} else if decoded.Hasher().Algorithm() != hasher.Algorithm() {
+			t.Fatalf("Expected %s, got %s", hasher.Algorithm(), decoded.Hasher().Algorithm())
+		}
+		for i, result := range decoded.TestMultiple(items...) {
+			if !result {
+				t.Errorf("Expected that decoded filter contains '%s'.", items[i])
+			}
+		}
+		if decoded.FillRatio() != filter.FillRatio() {
+			t.Errorf("Expected fill ratio %f, got %f", filter.FillRatio(), decoded.FillRatio())
+		}
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	filter := NewFilter(10, 0.01)
+	filter.Add([]byte("aaa"))
+	data, _ := filter.MarshalBinary()
+
+	// Unkeyed filters can be decoded into a zero filter.
+	var decoded BloomFilter
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if !decoded.Test([]byte("aaa")) {
+		t.Errorf("Expected that decoded filter contains 'aaa'.")
+	}
+
+	// modified function returns a copy of the encoded filter after applying
+	// the function provided and, optionally, updating its checksum.
+	modified := func(fn func([]byte), checksum bool) []byte {
+		result := append([]byte{}, data...)
+		fn(result)
+		if checksum {
+			body := result[:len(result)-checksumSize]
+			binary.BigEndian.PutUint32(result[len(body):], crc32.ChecksumIEEE(body))
+		}
+		return result
+	}
+
+	keyed := NewFilter(10, 0.01, WithHasher(DeriveSipHasher([]byte("secret"))))
+	keyedData, _ := keyed.MarshalBinary()
+
+	var cases = []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{"empty", nil, ErrFormat},
+		{"truncated", data[:len(data)-8], ErrChecksum},
+		{"magic", modified(func(d []byte) { d[0] = 'X' }, true), ErrFormat},
+		{"version", modified(func(d []byte) { d[4] = Version + 1 }, true), ErrVersion},
+		{"checksum", modified(func(d []byte) { d[headerSize] ^= 1 }, false), ErrChecksum},
+		{"hasher", modified(func(d []byte) { d[5] = 0 }, true), ErrHasher},
+		{"keyed", keyedData, ErrHasher},
+		{"zero hashes", modified(func(d []byte) { binary.BigEndian.PutUint64(d[14:], 0) }, true), ErrFormat},
+		{"too many hashes", modified(func(d []byte) { binary.BigEndian.PutUint64(d[14:], maxHashes+1) }, true), ErrFormat},
+		{"bits", modified(func(d []byte) { binary.BigEndian.PutUint64(d[6:], 1000) }, true), ErrFormat},
+		{"bits out of range", modified(func(d []byte) { d[len(d)-checksumSize-8] = 0xff }, true), ErrFormat},
+		{"bits overflow", craftedHeader(magic, 1<<64-1, 1, 1), ErrFormat},
+		{"bits without words", craftedHeader(magic, 1, 1, 1), ErrFormat},
+	}
+	for _, c := range cases {
+		if _, err := UnmarshalFilter(c.input); !errors.Is(err, c.expected) {
+			t.Errorf("Expected %v for %s input, got %v", c.expected, c.name, err)
+		}
+	}
+}
+
+// craftedHeader function returns an encoded filter with the magic string and
+// the parameters provided, a valid checksum and no words.
+func craftedHeader(magic []byte, m, k, n uint64) []byte {
+	data := make([]byte, headerSize+checksumSize)
+	copy(data, magic)
+	data[4], data[5] = Version, byte(FNV1a)
+	binary.BigEndian.PutUint64(data[6:], m)
+	binary.BigEndian.PutUint64(data[14:], k)
+	binary.BigEndian.PutUint64(data[22:], n)
+	binary.BigEndian.PutUint32(data[headerSize:], crc32.ChecksumIEEE(data[:headerSize]))
+	return data
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	filter := NewFilter(10, 0.01)
+	filter.Add([]byte("aaa"))
+	data, _ := filter.MarshalBinary()
+	f.Add(data)
+	f.Add(craftedHeader(magic, 1<<64-1, 1, 1))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Decoding must never panic, and the decoded filters must be encoded
+		// back into the same input.
+		var decoded BloomFilter
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			return
+		}
+		decoded.Test([]byte("aaa"))
+		if encoded, _ := decoded.MarshalBinary(); string(encoded) != string(data) {
+			t.Errorf("Expected the same encoding, got %x", encoded)
+		}
+	})
+}
+
+func TestMarshalCountingFilter(t *testing.T) {
+	items := [][]byte{[]byte("aaa"), []byte("bbb"), []byte("ccc")}
+	filter := NewCountingFilter(10, 0.01)
+	filter.Add(items...)
+	filter.Add(items[0])
+	data, _ := filter.MarshalBinary()
+
+	// Counting filters are not valid Bloom filters and vice versa.
+	if _, err := UnmarshalFilter(data); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+	bloomData, _ := NewFilter(10, 0.01).MarshalBinary()
+	if _, err := UnmarshalCountingFilter(bloomData); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+
+	// Crafted sizes that do not fit into the counters are rejected.
+	if _, err := UnmarshalCountingFilter(craftedHeader(countingMagic, 1<<64-1, 1, 1)); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+	overflow := NewCountingFilter(10, 0.01)
+	overflowData, _ := overflow.MarshalBinary()
+	binary.BigEndian.PutUint64(overflowData[6:], uint64(len(overflow.data))*wordCounters+1)
+	body := overflowData[:len(overflowData)-checksumSize]
+	binary.BigEndian.PutUint32(overflowData[len(body):], crc32.ChecksumIEEE(body))
+	if _, err := UnmarshalCountingFilter(overflowData); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+
+	decoded, err := UnmarshalCountingFilter(data)
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if decoded.M() != filter.M() || decoded.K() != filter.K() || decoded.N() != filter.N() {
+		t.Fatalf("Expected m, k, n = %d, %d, %d, got %d, %d, %d", filter.M(), filter.K(), filter.N(), decoded.M(), decoded.K(), decoded.N())
+	}
+
+	// The counters are kept, so the item added twice remains after removing
+	// it once.
+	decoded.Remove(items...)
+	if !decoded.Test(items[0]) {
+		t.Errorf("Expected that decoded filter contains '%s'.", items[0])
+	} else if decoded.Test(items[1]) {
+		t.Errorf("Expected that decoded filter not contains '%s'.", items[1])
+	}
+}
+
+func TestMarshalBlockedFilter(t *testing.T) {
+	items := make([][]byte, 100)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	filter := NewBlockedFilter(len(items), 0.001)
+	filter.Add(items...)
+	data, _ := filter.MarshalBinary()
+
+	// Blocked filters are not valid Bloom filters and vice versa.
+	if _, err := UnmarshalFilter(data); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+	bloomData, _ := NewFilter(len(items), 0.001).MarshalBinary()
+	if _, err := UnmarshalBlockedFilter(bloomData); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+
+	decoded, err := UnmarshalBlockedFilter(data)
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if decoded.M() != filter.M() || decoded.K() != filter.K() || decoded.Blocks() != filter.Blocks() {
+		t.Fatalf("Expected m, k, blocks = %d, %d, %d, got %d, %d, %d", filter.M(), filter.K(), filter.Blocks(), decoded.M(), decoded.K(), decoded.Blocks())
+	}
+	for i, result := range decoded.TestMultiple(items...) {
+		if !result {
+			t.Errorf("Expected that decoded filter contains '%s'.", items[i])
+		}
+	}
+
+	// Crafted sizes that do not fit into the words are rejected before
+	// checking the size of the blocks.
+	if _, err := UnmarshalBlockedFilter(craftedHeader(blockedMagic, 1<<64-1, 1, 1)); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	} else if _, err = UnmarshalBlockedFilter(craftedHeader(blockedMagic, 1<<63, 1, 1)); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+
+	// The number of bits must be a multiple of the size of a block.
+	copy(bloomData, blockedMagic)
+	body := bloomData[:len(bloomData)-checksumSize]
+	binary.BigEndian.PutUint32(bloomData[len(body):], crc32.ChecksumIEEE(body))
+	if _, err := UnmarshalBlockedFilter(bloomData); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+}
+
+func TestMarshalScalableFilter(t *testing.T) {
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	hasher := DeriveSipHasher([]byte("secret"))
+	filter := NewScalableFilter(100, 0.01, WithHasher(hasher))
+	filter.Add(items...)
+	data, _ := filter.MarshalBinary()
+
+	if _, err := UnmarshalScalableFilter(data); !errors.Is(err, ErrHasher) {
+		t.Errorf("Expected %v, got %v", ErrHasher, err)
+	} else if _, err := UnmarshalScalableFilter(data[:len(data)-1]); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected %v, got %v", ErrChecksum, err)
+	}
+
+	decoded, err := UnmarshalScalableFilter(data, WithHasher(hasher))
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if decoded.Slices() != filter.Slices() || decoded.Count() != filter.Count() || decoded.M() != filter.M() {
+		t.Fatalf("Expected %d filters with %d items, got %d with %d", filter.Slices(), filter.Count(), decoded.Slices(), decoded.Count())
+	}
+	for i, result := range decoded.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that decoded filter contains '%s'.", items[i])
+		}
+	}
+
+	// The decoded filter keeps growing as the original one.
+	more := make([][]byte, 1000)
+	for i := range more {
+		more[i] = []byte(fmt.Sprintf("more-%d", i))
+	}
+	filter.Add(more...)
+	decoded.Add(more...)
+	if decoded.Slices() != filter.Slices() || decoded.Count() != filter.Count() {
+		t.Errorf("Expected %d filters with %d items, got %d with %d", filter.Slices(), filter.Count(), decoded.Slices(), decoded.Count())
+	}
+
+	// Filters with unexpected parameters are rejected.
+	other := NewScalableFilter(200, 0.01, WithHasher(hasher))
+	otherData, _ := other.MarshalBinary()
+	binary.BigEndian.PutUint64(otherData[7:], 100)
+	body := otherData[:len(otherData)-checksumSize]
+	binary.BigEndian.PutUint32(otherData[len(body):], crc32.ChecksumIEEE(body))
+	if _, err := UnmarshalScalableFilter(otherData, WithHasher(hasher)); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+}
+
+func TestMarshalGolombSet(t *testing.T) {
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	hasher := DeriveSipHasher([]byte("secret"))
+	set := NewGolombSet(items, 0.001, WithHasher(hasher))
+	data, _ := set.MarshalBinary()
+	if len(data) != golombHeaderSize+len(set.data)+checksumSize {
+		t.Fatalf("Expected %d bytes, got %d", golombHeaderSize+len(set.data)+checksumSize, len(data))
+	} else if _, err := UnmarshalGolombSet(data); !errors.Is(err, ErrHasher) {
+		t.Errorf("Expected %v, got %v", ErrHasher, err)
+	}
+
+	decoded, err := UnmarshalGolombSet(data, WithHasher(hasher))
+	if err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if decoded.N() != set.N() || decoded.Count() != set.Count() || decoded.FalsePositiveRate() != set.FalsePositiveRate() {
+		t.Fatalf("Expected n, count = %d, %d, got %d, %d", set.N(), set.Count(), decoded.N(), decoded.Count())
+	}
+	for i, result := range decoded.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that decoded set contains '%s'.", items[i])
+		}
+	}
+
+	// Sets with invalid parameters or values are rejected.
+	resign := func(data []byte) []byte {
+		body := data[:len(data)-checksumSize]
+		binary.BigEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+		return data
+	}
+	invalid := map[string]func(data []byte){
+		"count":    func(data []byte) { binary.BigEndian.PutUint64(data[23:], uint64(set.Count()+1)) },
+		"range":    func(data []byte) { binary.BigEndian.PutUint64(data[15:], 1) },
+		"overflow": func(data []byte) { binary.BigEndian.PutUint64(data[7:], 1<<62) },
+		"padding":  func(data []byte) { data[len(data)-checksumSize-1] |= 1 },
+	}
+	for name, modify := range invalid {
+		modified := append([]byte{}, data...)
+		modify(modified)
+		if _, err := UnmarshalGolombSet(resign(modified), WithHasher(hasher)); !errors.Is(err, ErrFormat) {
+			t.Errorf("Expected %v for invalid %s, got %v", ErrFormat, name, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package bloomfilter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+func TestBitWriter(t *testing.T) {
+	var writer bitWriter
+	writer.writeUnary(0)
+	writer.writeUnary(11)
+	writer.writeBits(0x2a5, 10)
+	writer.writeBits(1, 1)
+	if writer.offset != 1+12+10+1 || len(writer.data) != 3 {
+		t.Fatalf("Expected %d bits into 3 bytes, got %d into %d", 1+12+10+1, writer.offset, len(writer.data))
+	}
+
+	reader := bitReader{data: writer.data}
+	if value, ok := reader.readUnary(); !ok || value != 0 {
+		t.Errorf("Expected 0, got %d", value)
+	} else if value, ok = reader.readUnary(); !ok || value != 11 {
+		t.Errorf("Expected 11, got %d", value)
+	} else if value, ok = reader.readBits(10); !ok || value != 0x2a5 {
+		t.Errorf("Expected %x, got %x", 0x2a5, value)
+	} else if value, ok = reader.readBits(1); !ok || value != 1 {
+		t.Errorf("Expected 1, got %d", value)
+	} else if reader.readPadding() != 0 {
+		t.Error("Expected empty padding")
+	} else if _, ok = reader.readBits(1); ok {
+		t.Error("Expected end of data")
+	} else if _, ok = reader.readUnary(); ok {
+		t.Error("Expected end of data")
+	}
+}
+
+func TestGolombSet(t *testing.T) {
+	var n, fp = 10000, 0.001
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	set := NewGolombSet(append(items, items[:100]...), fp)
+	if set.N() != uint(n+100) || set.Count() > uint(n) || set.Count() < uint(n)-10 {
+		t.Fatalf("Expected %d items and around %d values, got %d and %d", n+100, n, set.N(), set.Count())
+	}
+	for i, result := range set.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that set contains '%s'.", items[i])
+		}
+	}
+
+	// Check the actual false positive rate with items not added.
+	var positives int
+	for i := 0; i < n; i++ {
+		if set.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(n); actual > fp*2 {
+		t.Errorf("Expected false positive rate close to %f, got %f", fp, actual)
+	}
+
+	// The set requires less bits than a Bloom filter.
+	if bits, filterBits := uint(len(set.data))*8, NewFilter(n, fp).M(); bits >= filterBits {
+		t.Errorf("Expected less than %d bits, got %d", filterBits, bits)
+	}
+
+	if err := set.Add(items[0]); !errors.Is(err, membership.ErrUnsupported) {
+		t.Errorf("Expected %v, got %v", membership.ErrUnsupported, err)
+	} else if err = set.Remove(items[0]); !errors.Is(err, membership.ErrUnsupported) {
+		t.Errorf("Expected %v, got %v", membership.ErrUnsupported, err)
+	}
+
+	// An empty set does not contain any item.
+	empty := NewGolombSet(nil, fp)
+	if empty.Count() != 0 || empty.Test(items[0]) {
+		t.Errorf("Expected that empty set not contains '%s'.", items[0])
+	}
+}
@@ -0,0 +1,181 @@
+package bloomfilter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// HashAlgorithm type identifies the hash function of a Hasher.
+type HashAlgorithm uint8
+
+// Hash functions provided by the package.
+const (
+	FNV1a   HashAlgorithm = iota + 1 // unkeyed 64-bit FNV-1a, the default
+	SHA256                           // unkeyed SHA-256 truncated to 64 bits
+	SipHash                          // keyed SipHash-2-4
+)
+
+// String function returns the name of the hash algorithm.
+func (alg HashAlgorithm) String() string {
+	switch alg {
+	case FNV1a:
+		return "fnv1a"
+	case SHA256:
+		return "sha256"
+	case SipHash:
+		return "siphash"
+	}
+	return "unknown"
+}
+
+// sipHashDomain contains the label used to derive the SipHash keys from the
+// session secrets, to avoid that the keys are valid for any other purpose.
+const sipHashDomain = "gopsi/v1/bloomfilter/siphash"
+
+// Parameters of the 64-bit FNV-1a hash function, read more here:
+// http://www.isthe.com/chongo/tech/comp/fnv/index.html.
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// Hasher interface defines the 64-bit hash function used by a BloomFilter to
+// calculate the positions of its items, and the algorithm that identifies it.
+// Sum64 must be safe for concurrent use. The unkeyed hash functions are
+// predictable, so anyone can precompute the positions of any item and craft
+// items that collide into the filter. A keyed hash function, such as
+// SipHasher, avoids it as long as its key remains secret.
+type Hasher interface {
+	Sum64(data []byte) uint64
+	Algorithm() HashAlgorithm
+}
+
+// FNVHasher function returns a Hasher that uses the unkeyed 64-bit FNV-1a hash
+// function. It is the fastest option and the default of NewFilter.
+func FNVHasher() Hasher {
+	return fnvHasher{}
+}
+
+// SHA256Hasher function returns a Hasher that uses the first 64 bits of the
+// unkeyed SHA-256 hash function.
+func SHA256Hasher() Hasher {
+	return sha256Hasher{}
+}
+
+// SipHasher function returns a Hasher that uses the SipHash-2-4 keyed hash
+// function with the key provided. Read more about SipHash here:
+// https://www.aumasson.jp/siphash/siphash.pdf.
+func SipHasher(key [16]byte) Hasher {
+	return sipHasher{
+		k0: binary.LittleEndian.Uint64(key[:8]),
+		k1: binary.LittleEndian.Uint64(key[8:]),
+	}
+}
+
+// DeriveSipHasher function returns a SipHasher whose key is derived from the
+// session secret provided using HMAC-SHA256, so the filters of the session
+// cannot be precomputed by anyone that does not know the secret.
+func DeriveSipHasher(secret []byte) Hasher {
+	var mac = hmac.New(sha256.New, secret)
+	mac.Write([]byte(sipHashDomain))
+
+	var key [16]byte
+	copy(key[:], mac.Sum(nil))
+	return SipHasher(key)
+}
+
+// fnvHasher struct implements Hasher using FNV-1a.
+type fnvHasher struct{}
+
+// Sum64 function returns the FNV-1a hash of the data provided.
+func (fnvHasher) Sum64(data []byte) uint64 {
+	var hashed uint64 = fnvOffset64
+	for _, c := range data {
+		hashed ^= uint64(c)
+		hashed *= fnvPrime64
+	}
+	return hashed
+}
+
+// Algorithm function returns FNV1a.
+func (fnvHasher) Algorithm() HashAlgorithm {
+	return FNV1a
+}
+
+// sha256Hasher struct implements Hasher using SHA-256.
+type sha256Hasher struct{}
+
+// Sum64 function returns the first 64 bits of the SHA-256 hash of the data
+// provided.
+func (sha256Hasher) Sum64(data []byte) uint64 {
+	var hashed [sha256.Size]byte = sha256.Sum256(data)
+	return binary.BigEndian.Uint64(hashed[:8])
+}
+
+// Algorithm function returns SHA256.
+func (sha256Hasher) Algorithm() HashAlgorithm {
+	return SHA256
+}
+
+// sipHasher struct implements Hasher using SipHash-2-4 with the key splitted
+// into two little-endian words (k0 and k1).
+type sipHasher struct {
+	k0, k1 uint64
+}
+
+// Sum64 function returns the SipHash-2-4 hash of the data provided.
+func (h sipHasher) Sum64(data []byte) uint64 {
+	var v0 uint64 = h.k0 ^ 0x736f6d6570736575
+	var v1 uint64 = h.k1 ^ 0x646f72616e646f6d
+	var v2 uint64 = h.k0 ^ 0x6c7967656e657261
+	var v3 uint64 = h.k1 ^ 0x7465646279746573
+	var round = func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	// Compress every 8 bytes word of the data with two rounds.
+	var last uint64 = uint64(len(data)) << 56
+	for ; len(data) >= 8; data = data[8:] {
+		var m uint64 = binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+
+	// Compress the remaining bytes with the data length into the last word,
+	// then finalize with four rounds.
+	for i, c := range data {
+		last |= uint64(c) << (8 * i)
+	}
+	v3 ^= last
+	round()
+	round()
+	v0 ^= last
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// Algorithm function returns SipHash.
+func (sipHasher) Algorithm() HashAlgorithm {
+	return SipHash
+}
@@ -0,0 +1,93 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSipHasher(t *testing.T) {
+	// Test vectors of the SipHash-2-4 reference implementation, with the key
+	// 00 01 ... 0f and the messages 00 01 ... of each length.
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	var message [15]byte
+	for i := range message {
+		message[i] = byte(i)
+	}
+
+	var cases = []struct {
+		length   int
+		expected uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{1, 0x74f839c593dc67fd},
+		{15, 0xa129ca6149be45e5},
+	}
+	hasher := SipHasher(key)
+	for _, c := range cases {
+		if result := hasher.Sum64(message[:c.length]); result != c.expected {
+			t.Errorf("Expected %x for length %d, got %x", c.expected, c.length, result)
+		}
+	}
+}
+
+func TestSHA256Hasher(t *testing.T) {
+	input := []byte("hello world")
+	hashed := sha256.Sum256(input)
+	if expected, result := binary.BigEndian.Uint64(hashed[:8]), SHA256Hasher().Sum64(input); result != expected {
+		t.Errorf("Expected %x, got %x", expected, result)
+	}
+}
+
+func TestDeriveSipHasher(t *testing.T) {
+	input := []byte("hello world")
+	hasherA := DeriveSipHasher([]byte("secret"))
+	hasherB := DeriveSipHasher([]byte("secret"))
+	hasherC := DeriveSipHasher([]byte("other secret"))
+	if hasherA.Sum64(input) != hasherB.Sum64(input) {
+		t.Errorf("Expected same hash for the same secret")
+	} else if hasherA.Sum64(input) == hasherC.Sum64(input) {
+		t.Errorf("Expected different hash for different secrets")
+	} else if hasherA.Algorithm() != SipHash {
+		t.Errorf("Expected %s, got %s", SipHash, hasherA.Algorithm())
+	}
+}
+
+func TestFilterHashers(t *testing.T) {
+	items := [][]byte{[]byte("aaa"), []byte("bbb"), []byte("ccc")}
+	hashers := []Hasher{FNVHasher(), SHA256Hasher(), DeriveSipHasher([]byte("secret"))}
+
+	var layouts [][]byte
+	for _, hasher := range hashers {
+		filter := NewFilter(len(items), 0.001, WithHasher(hasher))
+		if filter.Hasher().Algorithm() != hasher.Algorithm() {
+			t.Errorf("Expected %s, got %s", hasher.Algorithm(), filter.Hasher().Algorithm())
+		}
+
+		filter.Add(items...)
+		for _, item := range items {
+			if !filter.Test(item) {
+				t.Errorf("Expected that %s filter contains '%s'.", hasher.Algorithm(), item)
+			}
+		}
+
+		layout := make([]byte, len(filter.data)*8)
+		for i, word := range filter.data {
+			binary.BigEndian.PutUint64(layout[i*8:], word)
+		}
+		layouts = append(layouts, layout)
+	}
+
+	// Every hash function must result into a different filter layout.
+	for i := range layouts {
+		for j := i + 1; j < len(layouts); j++ {
+			if bytes.Equal(layouts[i], layouts[j]) {
+				t.Errorf("Expected different layouts for %s and %s", hashers[i].Algorithm(), hashers[j].Algorithm())
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package bloomfilter
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+// Parameters of the growth of a ScalableFilter: each new filter can store
+// twice the items of the previous one (growthRatio), with half of its false
+// positive rate (tighteningRatio).
+const (
+	growthRatio     = 2
+	tighteningRatio = 0.5
+)
+
+// maxSlices contains the maximum number of filters of a ScalableFilter, much
+// more than the required for any number of items.
+const maxSlices = 64
+
+// ScalableFilter struct contains a scalable Bloom filter, that grows as items
+// are added instead of requiring the number of items in advance. It is
+// composed by a list of filters (filters), each one with twice the size of
+// the previous one and half of its false positive rate, starting with the
+// initial size (size) and the false positive rate fp * (1 - 0.5), so the false
+// positive rate of the whole filter remains below the one provided (fp) no
+// matter the number of items. New items are added to the last filter, and a
+// new one is created when it is full according to the number of items added
+// to it (last). It also contains the hash function of its filters (hasher)
+// and the total number of items added (count). It is safe for concurrent use.
+// Read more about scalable Bloom filters here:
+// https://gsd.di.uminho.pt/members/cbm/ps/dbloom.pdf.
+type ScalableFilter struct {
+	mu      sync.RWMutex
+	filters []*BloomFilter
+	size    uint
+	fp      float64
+	hasher  Hasher
+	last    uint
+	count   uint
+}
+
+// NewScalableFilter function initializes a new ScalableFilter with the initial
+// size and the false positive rate provided, creating its first filter. The
+// initial size is only an estimation of the number of items, the filter keeps
+// the false positive rate provided if it is exceeded, but it uses less memory
+// if it is accurate. The options provided are applied to every filter.
+func NewScalableFilter(size int, fp float64, options ...Option) *ScalableFilter {
+	var p params = newParams(size, fp, options)
+	var filter = &ScalableFilter{size: p.n, fp: fp, hasher: p.hasher}
+	filter.grow()
+	return filter
+}
+
+// grow function appends a new filter to the list of filters, with the size and
+// false positive rate of its position.
+func (f *ScalableFilter) grow() {
+	var size, fp = f.slice(len(f.filters))
+	f.filters = append(f.filters, NewFilter(int(size), fp, WithHasher(f.hasher)))
+	f.last = 0
+}
+
+// slice function returns the size and the false positive rate of the filter of
+// the position provided: size * 2^i and fp * (1 - 0.5) * 0.5^i.
+func (f *ScalableFilter) slice(i int) (uint, float64) {
+	var size uint = f.size
+	var fp float64 = f.fp * (1 - tighteningRatio)
+	for ; i > 0; i-- {
+		size *= growthRatio
+		fp *= tighteningRatio
+	}
+	return size, fp
+}
+
+// Add function inserts one (or more) items into the last filter, creating a
+// new one when it is full. The items that the filter already contains are
+// skipped, so they do not fill it. It returns an error if the filter reaches
+// the maximum number of filters, which requires more items than the ones that
+// fit into memory.
+func (f *ScalableFilter) Add(items ...[]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, item := range items {
+		if f.test(item) {
+			continue
+		}
+
+		var current *BloomFilter = f.filters[len(f.filters)-1]
+		if f.last >= current.N() {
+			if len(f.filters) == maxSlices {
+				return errors.New("bloomfilter: maximum number of filters reached")
+			}
+			f.grow()
+			current = f.filters[len(f.filters)-1]
+		}
+
+		current.Add(item)
+		f.last++
+		f.count++
+	}
+	return nil
+}
+
+// Test function checks if any of the filters contains the item provided.
+func (f *ScalableFilter) Test(item []byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.test(item)
+}
+
+// test function performs the same action as Test without locking the filter.
+func (f *ScalableFilter) test(item []byte) bool {
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		if f.filters[i].Test(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestMultiple function tests multiple items at the same time, using the
+// ScalableFilter.Test function.
+func (f *ScalableFilter) TestMultiple(items ...[]byte) (results []bool) {
+	results = make([]bool, len(items))
+	for i, item := range items {
+		results[i] = f.Test(item)
+	}
+	return
+}
+
+// Remove function returns membership.ErrUnsupported, because the filters of a
+// scalable filter do not support removing items.
+func (f *ScalableFilter) Remove(items ...[]byte) error {
+	return membership.ErrUnsupported
+}
+
+// Count function returns the number of items added to the filter, skipping
+// the ones that it already contained.
+func (f *ScalableFilter) Count() uint {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.count
+}
+
+// Slices function returns the number of filters of the scalable filter.
+func (f *ScalableFilter) Slices() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.filters)
+}
+
+// M function returns the total number of bits of the filters.
+func (f *ScalableFilter) M() (m uint) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, filter := range f.filters {
+		m += filter.M()
+	}
+	return
+}
+
+// EstimatedFalsePositiveRate function returns the false positive rate of the
+// filter according to the bits actually set of every filter, following the
+// formula: fp = 1 - (1 - fp_0) * (1 - fp_1) * ... * (1 - fp_n), that remains
+// close to the false positive rate provided to NewScalableFilter.
+func (f *ScalableFilter) EstimatedFalsePositiveRate() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var negative float64 = 1
+	for _, filter := range f.filters {
+		negative *= 1 - filter.EstimatedFalsePositiveRate()
+	}
+	return 1 - negative
+}
+
+// Hasher function returns the hash function of the filters.
+func (f *ScalableFilter) Hasher() Hasher {
+	return f.hasher
+}
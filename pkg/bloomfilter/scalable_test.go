@@ -0,0 +1,94 @@
+package bloomfilter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+var _ membership.Filter = (*ScalableFilter)(nil)
+
+func TestScalableFilter(t *testing.T) {
+	var size, n, fp = 100, 10000, 0.01
+	filter := NewScalableFilter(size, fp)
+	if filter.Slices() != 1 {
+		t.Fatalf("Expected 1 filter, got %d", filter.Slices())
+	}
+
+	// Add many more items than the initial size, the filter must grow keeping
+	// every item.
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	if err := filter.Add(items...); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	}
+	for i, result := range filter.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", items[i])
+		}
+	}
+
+	// 100 * (1 + 2 + ... + 2^6) = 12700 >= 10000 > 6300.
+	if filter.Slices() != 7 {
+		t.Errorf("Expected 7 filters, got %d", filter.Slices())
+	} else if count := filter.Count(); count > uint(n) || float64(count) < float64(n)*(1-fp*2) {
+		// The false positives are skipped as already contained items.
+		t.Errorf("Expected around %d items, got %d", n, count)
+	}
+
+	// The false positive rate remains close to the one provided.
+	if estimated := filter.EstimatedFalsePositiveRate(); estimated > fp*2 {
+		t.Errorf("Expected estimated false positive rate close to %f, got %f", fp, estimated)
+	}
+	var positives int
+	for i := 0; i < n; i++ {
+		if filter.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(n); actual > fp*2 {
+		t.Errorf("Expected false positive rate close to %f, got %f", fp, actual)
+	}
+
+	// A Bloom filter of the initial size largely exceeds it.
+	reference := NewFilter(size, fp)
+	reference.Add(items...)
+	if estimated := reference.EstimatedFalsePositiveRate(); estimated < fp*10 {
+		t.Errorf("Expected estimated false positive rate far above %f, got %f", fp, estimated)
+	}
+
+	// Items already contained are not added again.
+	var count uint = filter.Count()
+	filter.Add(items[:100]...)
+	if filter.Count() != count {
+		t.Errorf("Expected %d items, got %d", count, filter.Count())
+	} else if err := filter.Remove(items[0]); !errors.Is(err, membership.ErrUnsupported) {
+		t.Errorf("Expected %v, got %v", membership.ErrUnsupported, err)
+	}
+}
+
+func TestConcurrentScalableFilter(t *testing.T) {
+	var n, workers = 500, 8
+	filter := NewScalableFilter(10, 0.001)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				item := []byte(fmt.Sprintf("item-%d-%d", w, i))
+				filter.Add(item)
+				if !filter.Test(item) {
+					t.Errorf("Expected that filter contains '%s'.", item)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,349 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// paramsVersion contains the version of the parameters agreement messages.
+// The version 2 adds the confirmation of the response (read more in
+// ConfirmParams).
+const paramsVersion = 2
+
+// paramsDomain contains the prefix of every signed agreement message, followed
+// by the message kind, to avoid that its signatures are valid for any other
+// purpose or kind of message.
+const paramsDomain = "gopsi/v1/params/"
+
+// Kinds of agreement messages.
+const (
+	proposalKind     = "proposal"
+	responseKind     = "response"
+	confirmationKind = "confirmation"
+)
+
+// nonceSize contains the size in bytes of the agreement nonces.
+const nonceSize = 32
+
+// paramsMessage struct contains the content of the agreement messages: the
+// identity of the sender, a random nonce, the public parameters of its
+// configuration, only in the responses, the hash of the proposal answered and
+// the common prime and, only in the confirmations, the hash of the response
+// confirmed.
+type paramsMessage struct {
+	Version      int    `json:"version"`
+	Identity     []byte `json:"identity"`
+	Nonce        []byte `json:"nonce"`
+	Scheme       string `json:"scheme"`
+	Name         string `json:"name,omitempty"`
+	PrimeBits    int    `json:"prime_bits"`
+	ExponentBits int    `json:"exponent_bits"`
+	Proposal     []byte `json:"proposal,omitempty"`
+	Prime        []byte `json:"prime,omitempty"`
+	Response     []byte `json:"response,omitempty"`
+}
+
+// pendingParams struct contains the results of a response to a proposal that
+// are not stored into the client until the initiator confirms the response:
+// the cipher, the common prime, the identity of the initiator and the
+// response itself.
+type pendingParams struct {
+	cipher      CommutativeCipher
+	commonPrime *big.Int
+	peer        ed25519.PublicKey
+	response    []byte
+}
+
+// signedMessage struct contains an encoded paramsMessage and the signature of
+// its sender.
+type signedMessage struct {
+	Body      []byte `json:"body"`
+	Signature []byte `json:"signature"`
+}
+
+// Identity function returns the Ed25519 public key that identifies the current
+// client during the parameters agreement, to be shared with the other client
+// through a trusted channel.
+func (client *Client) Identity() ed25519.PublicKey {
+	if client.identity == nil {
+		return nil
+	}
+	return client.identity.Public().(ed25519.PublicKey)
+}
+
+// PeerIdentity function returns the Ed25519 public key of the other client
+// that signed the agreed parameters. If the client was configured to accept
+// any peer (read more in WithAnyPeer), it must be checked before trusting the
+// agreement. It returns nil if the parameters are not agreed yet.
+func (client *Client) PeerIdentity() ed25519.PublicKey {
+	return client.peer
+}
+
+// ProposeParams function starts the parameters agreement as initiator. It
+// returns a proposal, signed with the client identity, that contains the public
+// parameters of the client configuration, to be sent to the other client (read
+// more in RespondParams). The agreement takes three messages: the proposal,
+// the response of the other client and the confirmation of the response (read
+// more in AcceptParams and ConfirmParams). It replaces the RSA based GenEncryptedPrime and
+// SetEncryptedPrime steps, authenticating the common prime instead of
+// encrypting it. Both clients must be initialized with the identity of the
+// other one (read more in WithPeerIdentity), or explicitly accept any peer
+// (read more in WithAnyPeer), otherwise it returns an error.
+func (client *Client) ProposeParams() ([]byte, error) {
+	if client.identity == nil {
+		return nil, errors.New("client not initialized")
+	} else if err := client.checkPeer(); err != nil {
+		return nil, err
+	} else if client.cipher != nil && client.CommonPrime != nil {
+		return nil, errors.New("common prime already defined, create a new instance")
+	}
+
+	var msg paramsMessage = client.paramsMessage()
+	msg.Nonce = make([]byte, nonceSize)
+	if _, err := rand.Read(msg.Nonce); err != nil {
+		return nil, err
+	}
+
+	proposal, err := client.sign(proposalKind, msg)
+	if err != nil {
+		return nil, err
+	}
+	client.proposal = proposal
+	return proposal, nil
+}
+
+// RespondParams function receives the proposal of the other client and,
+// if it is correctly signed and its parameters match the client configuration,
+// generates the common prime and the client cipher with it. It returns a
+// response, signed with the client identity and bound to the proposal
+// received, that contains the common prime and a random nonce, to be sent to
+// the other client (read more in AcceptParams). The common prime and the
+// cipher are not stored into the client until the other client confirms the
+// response (read more in ConfirmParams), because a signed proposal can be
+// replayed by anyone, but only its signer can confirm the fresh response.
+func (client *Client) RespondParams(proposal []byte) ([]byte, error) {
+	return client.RespondParamsContext(context.Background(), proposal)
+}
+
+// RespondParamsContext function performs the same action as RespondParams but
+// checking the context provided before start and before storing the results,
+// returning the context error if it is done. The client is not modified if it
+// fails.
+func (client *Client) RespondParamsContext(ctx context.Context, proposal []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if client.identity == nil {
+		return nil, errors.New("client not initialized")
+	} else if err := client.checkPeer(); err != nil {
+		return nil, err
+	} else if client.cipher != nil && client.CommonPrime != nil {
+		return nil, errors.New("common prime already defined, create a new instance")
+	} else if client.pending != nil {
+		return nil, errors.New("parameters already responded, create a new instance")
+	}
+
+	extMsg, err := client.open(proposalKind, proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	commonPrime, err := client.newCommonPrime()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := client.newCipher(commonPrime)
+	if err != nil {
+		return nil, err
+	}
+
+	var hash = sha256.Sum256(proposal)
+	var msg paramsMessage = client.paramsMessage()
+	msg.Nonce = make([]byte, nonceSize)
+	msg.Proposal, msg.Prime = hash[:], commonPrime.Bytes()
+	if _, err = rand.Read(msg.Nonce); err != nil {
+		return nil, err
+	}
+
+	response, err := client.sign(responseKind, msg)
+	if err != nil {
+		return nil, err
+	} else if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	client.pending = &pendingParams{
+		cipher:      cipher,
+		commonPrime: commonPrime,
+		peer:        ed25519.PublicKey(extMsg.Identity),
+		response:    response,
+	}
+	return response, nil
+}
+
+// AcceptParams function receives the response of the other client to the
+// proposal of the current one and, if it is correctly signed, bound to the
+// proposal and its parameters match the client configuration, validates the
+// common prime (read more in SetEncryptedPrime) and initializes the client
+// cipher with it. It returns a confirmation, signed with the client identity
+// and bound to the response received, to be sent to the other client (read
+// more in ConfirmParams).
+func (client *Client) AcceptParams(response []byte) ([]byte, error) {
+	return client.AcceptParamsContext(context.Background(), response)
+}
+
+// AcceptParamsContext function performs the same action as AcceptParams but
+// checking the context provided before start and before storing the results,
+// returning the context error if it is done. The client is not modified if it
+// fails.
+func (client *Client) AcceptParamsContext(ctx context.Context, response []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if client.proposal == nil {
+		return nil, errors.New("parameters not proposed")
+	} else if client.cipher != nil && client.CommonPrime != nil {
+		return nil, errors.New("common prime already defined, create a new instance")
+	}
+
+	extMsg, err := client.open(responseKind, response)
+	if err != nil {
+		return nil, err
+	}
+
+	var hash = sha256.Sum256(client.proposal)
+	if !bytes.Equal(hash[:], extMsg.Proposal) {
+		return nil, errors.New("response does not match the proposal")
+	}
+
+	var commonPrime *big.Int = new(big.Int).SetBytes(extMsg.Prime)
+	if err = client.checkCommonPrime(commonPrime); err != nil {
+		return nil, err
+	}
+	cipher, err := client.newCipher(commonPrime)
+	if err != nil {
+		return nil, err
+	}
+
+	hash = sha256.Sum256(response)
+	var msg paramsMessage = client.paramsMessage()
+	msg.Nonce, msg.Response = make([]byte, nonceSize), hash[:]
+	if _, err = rand.Read(msg.Nonce); err != nil {
+		return nil, err
+	}
+
+	confirmation, err := client.sign(confirmationKind, msg)
+	if err != nil {
+		return nil, err
+	} else if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	client.cipher, client.CommonPrime = cipher, commonPrime
+	client.peer = ed25519.PublicKey(extMsg.Identity)
+	return confirmation, nil
+}
+
+// ConfirmParams function receives the confirmation of the other client to the
+// response of the current one and, if it is signed by the same client that
+// signed the proposal and bound to the response, stores the common prime and
+// the cipher generated by RespondParams into the client. The client is not
+// modified if it fails.
+func (client *Client) ConfirmParams(confirmation []byte) error {
+	if client.pending == nil {
+		return errors.New("parameters not responded")
+	}
+
+	extMsg, err := client.open(confirmationKind, confirmation)
+	if err != nil {
+		return err
+	} else if !client.pending.peer.Equal(ed25519.PublicKey(extMsg.Identity)) {
+		return errors.New("confirmation not signed by the proposer")
+	}
+
+	var hash = sha256.Sum256(client.pending.response)
+	if !bytes.Equal(hash[:], extMsg.Response) {
+		return errors.New("confirmation does not match the response")
+	}
+
+	client.cipher, client.CommonPrime = client.pending.cipher, client.pending.commonPrime
+	client.peer, client.pending = client.pending.peer, nil
+	return nil
+}
+
+// checkPeer function returns an error if the client has neither a trusted peer
+// identity nor accepts any peer, because any attacker in the middle could sign
+// the agreement messages then.
+func (client *Client) checkPeer() error {
+	if client.trustedPeer == nil && !client.anyPeer {
+		return errors.New("trusted peer identity not defined")
+	}
+	return nil
+}
+
+// paramsMessage function returns an agreement message with the identity and
+// the public parameters of the client configuration.
+func (client *Client) paramsMessage() paramsMessage {
+	var msg = paramsMessage{
+		Version:      paramsVersion,
+		Identity:     client.Identity(),
+		Scheme:       SRAScheme,
+		PrimeBits:    client.config.PrimeBits,
+		ExponentBits: client.config.ExponentBits,
+	}
+	if client.config.Curve != nil {
+		msg.Scheme, msg.Name = ECScheme, client.config.Curve.Params().Name
+	} else if client.config.Group != nil {
+		msg.Name = client.config.Group.Name
+	}
+	return msg
+}
+
+// sign function encodes the agreement message provided and signs it, prefixed
+// by the kind provided, with the client identity.
+func (client *Client) sign(kind string, msg paramsMessage) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte = ed25519.Sign(client.identity, append([]byte(paramsDomain+kind), body...))
+	return json.Marshal(signedMessage{Body: body, Signature: signature})
+}
+
+// open function decodes the signed agreement message of the kind provided and
+// checks its signature, its sender (the trusted peer identity, if the client
+// has one) and that its parameters match the client configuration.
+func (client *Client) open(kind string, data []byte) (msg paramsMessage, err error) {
+	var signed signedMessage
+	if err = client.checkPeer(); err != nil {
+		return msg, err
+	} else if len(data) == 0 {
+		return msg, fmt.Errorf("empty %s", kind)
+	} else if err = json.Unmarshal(data, &signed); err != nil {
+		return msg, fmt.Errorf("error decoding %s: %w", kind, err)
+	} else if err = json.Unmarshal(signed.Body, &msg); err != nil {
+		return msg, fmt.Errorf("error decoding %s: %w", kind, err)
+	}
+
+	if msg.Version != paramsVersion {
+		return msg, fmt.Errorf("unsupported %s version %d", kind, msg.Version)
+	} else if len(msg.Identity) != ed25519.PublicKeySize || len(msg.Nonce) != nonceSize {
+		return msg, fmt.Errorf("malformed %s", kind)
+	} else if !ed25519.Verify(msg.Identity, append([]byte(paramsDomain+kind), signed.Body...), signed.Signature) {
+		return msg, fmt.Errorf("invalid %s signature", kind)
+	} else if client.trustedPeer != nil && !client.trustedPeer.Equal(ed25519.PublicKey(msg.Identity)) {
+		return msg, fmt.Errorf("%s not signed by the trusted peer", kind)
+	}
+
+	var own paramsMessage = client.paramsMessage()
+	if msg.Scheme != own.Scheme || msg.Name != own.Name || msg.PrimeBits != own.PrimeBits || msg.ExponentBits != own.ExponentBits {
+		return msg, fmt.Errorf("%s parameters do not match the configuration", kind)
+	}
+	return msg, nil
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+func TestAgreement(t *testing.T) {
+	// The RSA key of the default configuration is not generated, because it
+	// is not required by the agreement.
+	initPub, initKey, _ := ed25519.GenerateKey(rand.Reader)
+	respPub, respKey, _ := ed25519.GenerateKey(rand.Reader)
+	initiator, err := Init(WithIdentity(initKey), WithPeerIdentity(respPub))
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if initiator.rsaKey != nil {
+		t.Fatal("expected nil RSA key, got key")
+	}
+	responder, _ := Init(WithRSABits(0), WithIdentity(respKey), WithPeerIdentity(initPub))
+
+	if _, err = initiator.AcceptParams([]byte("{}")); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if err = responder.ConfirmParams([]byte("{}")); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	proposal, err := initiator.ProposeParams()
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	response, err := responder.RespondParams(proposal)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if responder.CommonPrime != nil {
+		t.Fatal("expected nil common prime before the confirmation")
+	}
+	confirmation, err := initiator.AcceptParams(response)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if err = responder.ConfirmParams(confirmation); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if initiator.CommonPrime.Cmp(responder.CommonPrime) != 0 {
+		t.Fatal("expected the same common prime")
+	} else if !initiator.PeerIdentity().Equal(responder.Identity()) {
+		t.Fatal("expected responder identity as peer identity")
+	} else if !responder.PeerIdentity().Equal(initiator.Identity()) {
+		t.Fatal("expected initiator identity as peer identity")
+	} else if _, err = responder.RespondParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if err = responder.ConfirmParams(confirmation); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var input = []string{"hello world"}
+	encrypted, _ := initiator.Encrypt(input)
+	reEncrypted, _ := responder.EncryptExt(encrypted)
+	initiator.PrepareIntersection(reEncrypted)
+	encByResponder, _ := responder.Encrypt(input)
+	common, _ := initiator.GetIntersection(encByResponder)
+	if result, err := responder.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(input, result) {
+		t.Fatalf("expected %v, got %v", input, result)
+	}
+
+	if _, err = responder.GenEncryptedPrime([]byte("pubkey")); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if initiator.rsaKey != nil {
+		t.Fatal("expected nil RSA key, got key")
+	}
+}
+
+func TestAgreementTrustedPeer(t *testing.T) {
+	// The agreement requires a trusted peer identity by default.
+	untrusted, _ := Init(WithRSABits(0))
+	initiator, _ := Init(WithRSABits(0), WithAnyPeer())
+	proposal, _ := initiator.ProposeParams()
+	if _, err := untrusted.ProposeParams(); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err = untrusted.RespondParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// The clients that accept any peer must check its identity after the
+	// agreement.
+	responder, _ := Init(WithRSABits(0), WithAnyPeer())
+	response, err := responder.RespondParams(proposal)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	confirmation, err := initiator.AcceptParams(response)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if err = responder.ConfirmParams(confirmation); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !initiator.PeerIdentity().Equal(responder.Identity()) {
+		t.Fatal("expected responder identity as peer identity")
+	} else if !responder.PeerIdentity().Equal(initiator.Identity()) {
+		t.Fatal("expected initiator identity as peer identity")
+	}
+
+	// The trusted peer identity is still checked if it is defined.
+	strict, _ := Init(WithRSABits(0), WithAnyPeer(), WithPeerIdentity(responder.Identity()))
+	if _, err = strict.RespondParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestAgreementAuthentication(t *testing.T) {
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	initiator, _ := Init(WithRSABits(0), WithAnyPeer())
+	responder, _ := Init(WithRSABits(0), WithAnyPeer())
+	impostor, _ := Init(WithRSABits(0), WithAnyPeer(), WithIdentity(other))
+	if !impostor.Identity().Equal(other.Public().(ed25519.PublicKey)) {
+		t.Fatal("expected identity provided")
+	}
+
+	// The responder only accepts proposals of its trusted peer.
+	proposal, _ := impostor.ProposeParams()
+	strict, _ := Init(WithRSABits(0), WithPeerIdentity(initiator.Identity()))
+	if _, err := strict.RespondParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if strict.CommonPrime != nil {
+		t.Fatal("expected nil, got common prime")
+	}
+
+	// The initiator only accepts responses to its own proposal.
+	proposal, _ = initiator.ProposeParams()
+	otherProposal, _ := impostor.ProposeParams()
+	response, _ := responder.RespondParams(otherProposal)
+	if _, err := initiator.AcceptParams(response); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// The initiator rejects modified responses.
+	responder, _ = Init(WithRSABits(0), WithAnyPeer())
+	response, _ = responder.RespondParams(proposal)
+	var modified = append([]byte{}, response...)
+	modified[len(modified)/2] ^= 0x01
+	if _, err := initiator.AcceptParams(modified); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err = initiator.AcceptParams(response); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+}
+
+func TestAgreementReplay(t *testing.T) {
+	initPub, initKey, _ := ed25519.GenerateKey(rand.Reader)
+	respPub, respKey, _ := ed25519.GenerateKey(rand.Reader)
+	initiator, _ := Init(WithRSABits(0), WithIdentity(initKey), WithPeerIdentity(respPub))
+	responder, _ := Init(WithRSABits(0), WithIdentity(respKey), WithPeerIdentity(initPub))
+	proposal, _ := initiator.ProposeParams()
+	response, _ := responder.RespondParams(proposal)
+	confirmation, _ := initiator.AcceptParams(response)
+	if err := responder.ConfirmParams(confirmation); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// An attacker that replays the proposal and the confirmation of a previous
+	// agreement to the same responder does not agree any common prime, because
+	// the new response has a new nonce that the initiator did not confirm.
+	replayed, _ := Init(WithRSABits(0), WithIdentity(respKey), WithPeerIdentity(initPub))
+	if _, err := replayed.RespondParams(proposal); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if err = replayed.ConfirmParams(confirmation); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if replayed.CommonPrime != nil || replayed.PeerIdentity() != nil {
+		t.Fatal("expected no agreement")
+	} else if _, err = replayed.Encrypt([]string{"hello world"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// The confirmation must be signed by the initiator of the proposal.
+	responder, _ = Init(WithRSABits(0), WithAnyPeer())
+	response, _ = responder.RespondParams(proposal)
+	impostor, _ := Init(WithRSABits(0), WithAnyPeer())
+	impostor.proposal = proposal
+	if forged, _ := impostor.AcceptParams(response); forged == nil {
+		t.Fatal("expected confirmation, got nil")
+	} else if err := responder.ConfirmParams(forged); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if responder.CommonPrime != nil {
+		t.Fatal("expected nil, got common prime")
+	}
+}
+
+func TestAgreementValidation(t *testing.T) {
+	// The responder rejects proposals with different parameters.
+	initiator, _ := Init(WithRSABits(0), WithAnyPeer())
+	responder, _ := Init(WithRSABits(0), WithAnyPeer(), WithGroup(sra.MODP2048))
+	proposal, _ := initiator.ProposeParams()
+	if _, err := responder.RespondParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// The initiator rejects a signed response with a composite prime of the
+	// configured size.
+	initiator, _ = Init(WithRSABits(0), WithAnyPeer(), WithPrimeBits(2048))
+	responder, _ = Init(WithRSABits(0), WithAnyPeer(), WithPrimeBits(2048))
+	proposal, _ = initiator.ProposeParams()
+	var composite = new(big.Int).Lsh(big.NewInt(1), 2047)
+	var hash = sha256.Sum256(proposal)
+	var msg = responder.paramsMessage()
+	msg.Nonce, msg.Proposal, msg.Prime = make([]byte, nonceSize), hash[:], composite.Bytes()
+	response, _ := responder.sign(responseKind, msg)
+	if _, err := initiator.AcceptParams(response); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if initiator.CommonPrime != nil {
+		t.Fatal("expected nil, got common prime")
+	}
+
+	// A signed proposal is not a valid response.
+	if _, err := initiator.AcceptParams(proposal); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/lucasmenendez/gopsi/internal/encoder"
+	"github.com/lucasmenendez/gopsi/pkg/ec"
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+var bigOne = big.NewInt(1)
+
+// Names of the schemes of the ciphers provided by the package.
+const (
+	SRAScheme = "sra"
+	ECScheme  = "ec"
+)
+
+// CommutativeCipher interface defines the commutative encryption scheme used
+// by the Client to encrypt and decrypt its items and the items of the other
+// client, where E_a(E_b(x)) = E_b(E_a(x)). Besides the encryption, it defines
+// how the items are mapped into elements of the scheme: EncodeElement and
+// DecodeElement map small numbers (such as the bytes of the items, used by
+// ByteEncoding) reversibly, and HashElement maps whole items irreversibly
+// (used by HashEncoding). Encrypt and Decrypt must return nil if the element
+// provided is not valid for the scheme, which is checked by ValidateElement.
+// The public parameters of the scheme are exported by Params.
+type CommutativeCipher interface {
+	Encrypt(element *big.Int) *big.Int
+	Decrypt(element *big.Int) *big.Int
+	EncodeElement(m *big.Int) (*big.Int, error)
+	DecodeElement(element *big.Int) (*big.Int, error)
+	HashElement(item []byte) (*big.Int, error)
+	ValidateElement(element *big.Int) error
+	Params() CipherParams
+}
+
+// CipherParams struct contains the public parameters of a CommutativeCipher:
+// the name of its scheme (such as SRAScheme or ECScheme), the name of its
+// group or curve (empty for random primes), its common prime and the size in
+// bytes of its encoded elements. Both clients must use ciphers with the same
+// parameters to get any intersection.
+type CipherParams struct {
+	Scheme      string
+	Name        string
+	Prime       *big.Int
+	ElementSize int
+}
+
+// CipherFactory type defines a function that instances a new CommutativeCipher
+// with a new secret key for the common prime provided.
+type CipherFactory func(commonPrime *big.Int) (CommutativeCipher, error)
+
+// SRAFactory function returns a CipherFactory that instances SRA ciphers with
+// secret exponents of the size provided. If a group is provided, the common
+// prime must be its prime and the ciphers work into its subgroup of quadratic
+// residues.
+func SRAFactory(group *sra.Group, exponentBits int) CipherFactory {
+	return func(commonPrime *big.Int) (CommutativeCipher, error) {
+		if commonPrime == nil {
+			return nil, errors.New("undefined common prime")
+		}
+
+		var key *sra.SRAKey
+		var err error
+		if group != nil {
+			if commonPrime.Cmp(group.P) != 0 {
+				return nil, errors.New("common prime does not match the group " + group.Name)
+			}
+			key, err = sra.NewGroupKey(group, exponentBits)
+		} else {
+			key, err = sra.NewKey(commonPrime, exponentBits)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &sraCipher{key: key, prime: commonPrime, group: group}, nil
+	}
+}
+
+// ECFactory function returns a CipherFactory that instances elliptic curve
+// ciphers over P-256 (read more in the ec package). The common prime must be
+// the prime of the curve field.
+func ECFactory() CipherFactory {
+	return func(commonPrime *big.Int) (CommutativeCipher, error) {
+		if commonPrime == nil || commonPrime.Cmp(ec.Prime()) != 0 {
+			return nil, errors.New("common prime does not match the curve " + ec.CurveName)
+		}
+
+		key, err := ec.NewKey()
+		if err != nil {
+			return nil, err
+		}
+		return &ecCipher{key}, nil
+	}
+}
+
+// sraCipher struct implements CommutativeCipher using an SRA key, its common
+// prime and, optionally, its group.
+type sraCipher struct {
+	key   *sra.SRAKey
+	prime *big.Int
+	group *sra.Group
+}
+
+// Encrypt function encrypts the element provided with the SRA key.
+func (c *sraCipher) Encrypt(element *big.Int) *big.Int {
+	if c.ValidateElement(element) != nil {
+		return nil
+	}
+	return c.key.Encrypt(element)
+}
+
+// Decrypt function decrypts the element provided with the SRA key.
+func (c *sraCipher) Decrypt(element *big.Int) *big.Int {
+	if c.ValidateElement(element) != nil {
+		return nil
+	}
+	return c.key.Decrypt(element)
+}
+
+// EncodeElement function encodes the number provided into the group subgroup
+// of quadratic residues if the cipher has a group, otherwise it returns the
+// number itself. It returns an error if the result is not a valid element,
+// for example, for the numbers 0 and 1.
+func (c *sraCipher) EncodeElement(m *big.Int) (*big.Int, error) {
+	if m == nil || m.Sign() <= 0 || m.Cmp(c.prime) >= 0 {
+		return nil, errors.New("number out of the range of the prime")
+	}
+
+	var element *big.Int = new(big.Int).Set(m)
+	if c.group != nil {
+		element = c.group.Encode(m)
+	}
+	if err := c.ValidateElement(element); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
+
+// DecodeElement function reverses EncodeElement.
+func (c *sraCipher) DecodeElement(element *big.Int) (*big.Int, error) {
+	if err := c.ValidateElement(element); err != nil {
+		return nil, err
+	} else if c.group != nil {
+		return c.group.Decode(element), nil
+	}
+	return new(big.Int).Set(element), nil
+}
+
+// HashElement function hashes the item provided into the range of the common
+// prime using encoder.StrToGroup, squaring the result into the group subgroup
+// of quadratic residues if the cipher has a group.
+func (c *sraCipher) HashElement(item []byte) (*big.Int, error) {
+	var element *big.Int = encoder.StrToGroup(string(item), c.prime)
+	if c.group != nil {
+		element = c.group.Square(element)
+	}
+	return element, nil
+}
+
+// ValidateElement function checks that the element provided is into the range
+// [2, p-2], rejecting the degenerate elements 0, 1 and p-1 (whose encryption
+// does not depend on the key or reveals its parity) and the elements out of
+// the range of the prime. If the cipher has a group, it also checks that the
+// element belongs to its subgroup of quadratic residues, which avoids small
+// subgroup attacks. The Client shifts the bytes encoded with ByteEncoding
+// into this range (read more in byteOffset).
+func (c *sraCipher) ValidateElement(element *big.Int) error {
+	if element == nil || element.Cmp(bigOne) <= 0 || element.Cmp(new(big.Int).Sub(c.prime, bigOne)) >= 0 {
+		return ErrDegenerateElement
+	} else if c.group != nil && !c.group.Contains(element) {
+		return ErrGroupMembership
+	}
+	return nil
+}
+
+// Params function returns the SRA cipher parameters.
+func (c *sraCipher) Params() CipherParams {
+	var params = CipherParams{
+		Scheme:      SRAScheme,
+		Prime:       c.prime,
+		ElementSize: (c.prime.BitLen() + 7) / 8,
+	}
+	if c.group != nil {
+		params.Name = c.group.Name
+	}
+	return params
+}
+
+// ecCipher struct implements CommutativeCipher using an elliptic curve key.
+type ecCipher struct {
+	*ec.Key
+}
+
+// EncodeElement function returns an error because the points of the curve
+// cannot encode arbitrary numbers reversibly.
+func (c *ecCipher) EncodeElement(m *big.Int) (*big.Int, error) {
+	return nil, errors.New("curves only support HashEncoding")
+}
+
+// DecodeElement function returns an error because the points of the curve
+// cannot encode arbitrary numbers reversibly.
+func (c *ecCipher) DecodeElement(element *big.Int) (*big.Int, error) {
+	return nil, errors.New("curves only support HashEncoding")
+}
+
+// HashElement function hashes the item provided to a point of the curve.
+func (c *ecCipher) HashElement(item []byte) (*big.Int, error) {
+	return ec.HashToElement(item)
+}
+
+// ValidateElement function checks that the element provided is a valid
+// encoded point of the curve.
+func (c *ecCipher) ValidateElement(element *big.Int) error {
+	_, _, err := ec.Decode(element)
+	return err
+}
+
+// Params function returns the elliptic curve cipher parameters.
+func (c *ecCipher) Params() CipherParams {
+	return CipherParams{
+		Scheme:      ECScheme,
+		Name:        ec.CurveName,
+		Prime:       ec.Prime(),
+		ElementSize: ec.ElementSize,
+	}
+}
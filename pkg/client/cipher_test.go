@@ -0,0 +1,146 @@
+package client
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/internal/encoder"
+	"github.com/lucasmenendez/gopsi/pkg/ec"
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+// mulCipher struct implements a CommutativeCipher test double that encrypts
+// multiplying by a random secret modulo the common prime, which is commutative
+// but not secure at all.
+type mulCipher struct {
+	prime, secret, secretInv *big.Int
+}
+
+func newMulCipher(commonPrime *big.Int) (CommutativeCipher, error) {
+	secret, err := rand.Int(rand.Reader, new(big.Int).Sub(commonPrime, big.NewInt(2)))
+	if err != nil {
+		return nil, err
+	}
+	secret.Add(secret, big.NewInt(2))
+	return &mulCipher{commonPrime, secret, new(big.Int).ModInverse(secret, commonPrime)}, nil
+}
+
+func (c *mulCipher) Encrypt(element *big.Int) *big.Int {
+	if c.ValidateElement(element) != nil {
+		return nil
+	}
+	return new(big.Int).Mod(new(big.Int).Mul(element, c.secret), c.prime)
+}
+
+func (c *mulCipher) Decrypt(element *big.Int) *big.Int {
+	if c.ValidateElement(element) != nil {
+		return nil
+	}
+	return new(big.Int).Mod(new(big.Int).Mul(element, c.secretInv), c.prime)
+}
+
+func (c *mulCipher) EncodeElement(m *big.Int) (*big.Int, error) {
+	return new(big.Int).Set(m), c.ValidateElement(m)
+}
+
+func (c *mulCipher) DecodeElement(element *big.Int) (*big.Int, error) {
+	return new(big.Int).Set(element), c.ValidateElement(element)
+}
+
+func (c *mulCipher) HashElement(item []byte) (*big.Int, error) {
+	return encoder.StrToGroup(string(item), c.prime), nil
+}
+
+func (c *mulCipher) ValidateElement(element *big.Int) error {
+	if element == nil || element.Sign() <= 0 || element.Cmp(c.prime) >= 0 {
+		return errors.New("element out of range")
+	}
+	return nil
+}
+
+func (c *mulCipher) Params() CipherParams {
+	return CipherParams{Scheme: "mul", Prime: c.prime, ElementSize: (c.prime.BitLen() + 7) / 8}
+}
+
+func TestWithCipher(t *testing.T) {
+	if _, err := Init(WithCipher(nil)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	for _, encoding := range []Encoding{ByteEncoding, HashEncoding} {
+		clientA, _ := Init(WithCipher(newMulCipher), WithEncoding(encoding))
+		clientB, _ := Init(WithCipher(newMulCipher), WithEncoding(encoding))
+		pubKey, _ := clientA.PubKey()
+		encPrime, _ := clientB.GenEncryptedPrime(pubKey)
+		if err := clientA.SetEncryptedPrime(encPrime); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+
+		if params, err := clientA.CipherParams(); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if params.Scheme != "mul" {
+			t.Fatalf("expected mul, got %s", params.Scheme)
+		}
+
+		var input = []string{"hello world"}
+		encrypted, _ := clientA.Encrypt(input)
+		reEncrypted, _ := clientB.EncryptExt(encrypted)
+		clientA.PrepareIntersection(reEncrypted)
+		encByB, _ := clientB.Encrypt(input)
+		common, _ := clientA.GetIntersection(encByB)
+		if result, err := clientB.ParseIntersection(common); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if !reflect.DeepEqual(input, result) {
+			t.Fatalf("expected %v, got %v", input, result)
+		}
+	}
+}
+
+func TestCipherFactories(t *testing.T) {
+	if _, err := SRAFactory(sra.FFDHE2048, 224)(sra.MODP2048.P); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err := ECFactory()(sra.FFDHE2048.P); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	sraCipher, err := SRAFactory(sra.FFDHE2048, 224)(sra.FFDHE2048.P)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	ecCipher, err := ECFactory()(ec.Prime())
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	var expected = []CipherParams{
+		{Scheme: SRAScheme, Name: sra.FFDHE2048.Name, Prime: sra.FFDHE2048.P, ElementSize: 256},
+		{Scheme: ECScheme, Name: ec.CurveName, Prime: ec.Prime(), ElementSize: ec.ElementSize},
+	}
+	for i, cipher := range []CommutativeCipher{sraCipher, ecCipher} {
+		if params := cipher.Params(); !reflect.DeepEqual(expected[i], params) {
+			t.Fatalf("expected %+v, got %+v", expected[i], params)
+		}
+
+		element, err := cipher.HashElement([]byte("hello world"))
+		if err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if err = cipher.ValidateElement(element); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if decrypted := cipher.Decrypt(cipher.Encrypt(element)); decrypted.Cmp(element) != 0 {
+			t.Fatalf("expected %s, got %s", element, decrypted)
+		} else if cipher.Encrypt(big.NewInt(0)) != nil {
+			t.Fatal("expected nil encrypting an invalid element")
+		}
+	}
+
+	if encoded, err := sraCipher.EncodeElement(big.NewInt('a')); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if decoded, _ := sraCipher.DecodeElement(encoded); decoded.Int64() != 'a' {
+		t.Fatalf("expected %d, got %s", 'a', decoded)
+	} else if _, err = ecCipher.EncodeElement(big.NewInt('a')); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
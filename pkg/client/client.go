@@ -1,39 +1,147 @@
 package client
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/lucasmenendez/gopsi/internal/encoder"
+	"github.com/lucasmenendez/gopsi/internal/parallel"
 	"github.com/lucasmenendez/gopsi/internal/rsa"
-	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
-	"github.com/lucasmenendez/gopsi/pkg/sra"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+	"github.com/lucasmenendez/gopsi/pkg/wire"
+)
+
+// filterFPRate contains the false positive rate of the filters created by the
+// clients to prepare the intersections.
+const filterFPRate = 0.0001
+
+// byteOffset contains the number added to every byte of the items encoded with
+// ByteEncoding before encoding it into an element of the cipher, which keeps
+// the bytes 0 and 1 away from the degenerate elements rejected by the ciphers.
+const byteOffset = 2
+
+// Encoding type defines how the Client maps every item to the elements of its
+// cipher before encrypting it.
+type Encoding int
+
+const (
+	// ByteEncoding encodes every byte of each item as a separate group element,
+	// which allows to decode the results without extra data but leaks the
+	// characters frequency and the items length to the other client. It is the
+	// default encoding.
+	ByteEncoding Encoding = iota
+	// HashEncoding hashes each whole item into a single group element (using
+	// CommutativeCipher.HashElement). The hash is not reversible, so the Client
+	// keeps the relation between the group elements and its original items to
+	// parse the intersection results locally.
+	HashEncoding
 )
 
 // Client struct contains all required parameters to perform a private set
 // intersection over another knowed Client.
 type Client struct {
 	CommonPrime *big.Int
-	sraKey      *sra.SRAKey
+	config      Config
+	encoding    Encoding
+	cipher      CommutativeCipher
+	factory     CipherFactory
 	rsaKey      *rsa.RSAKey
-	filter      *bloomfilter.BloomFilter
+	identity    ed25519.PrivateKey
+	trustedPeer ed25519.PublicKey
+	anyPeer     bool
+	peer        ed25519.PublicKey
+	proposal    []byte
+	pending     *pendingParams
+	filter      membership.Filter
+	match       MatchMode
+	plaintexts  map[string]string
+	workers     int
+	chunkSize   int
 }
 
-// Init function instances a Client generating a new RSA key pair.
-func Init() (client *Client, err error) {
-	client = &Client{}
+// Init function instances a Client with the options provided, generating a new
+// Ed25519 identity (unless one is provided with WithIdentity). The RSA key pair
+// is not generated until PubKey is called, so the clients that agree the
+// common prime with ProposeParams never generate it. If no configuration is
+// provided, it uses DefaultConfig. It returns an error if some option fails or
+// if the resulting configuration is below the MinimumConfig. If the
+// configuration defines a curve, the client uses HashEncoding, the only one
+// supported by the curve.
+func Init(options ...Option) (client *Client, err error) {
+	client = &Client{config: DefaultConfig}
+	for _, option := range options {
+		if err = option(client); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = client.config.Validate(); err != nil {
+		return nil, err
+	} else if client.config.Curve != nil {
+		client.encoding = HashEncoding
+	}
 
-	// Generate RSA keys pair
-	client.rsaKey, err = rsa.NewKey(1024)
+	if client.identity == nil {
+		if _, client.identity, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+	}
 	return
 }
 
+// Config function returns the configuration of the current client.
+func (client *Client) Config() Config {
+	return client.config
+}
+
+// SetEncoding function sets the encoding used by the current client to map its
+// items to the elements of its cipher. Both clients must use the same encoding
+// to get any intersection. It returns an error if the encoding is not supported
+// or if the client has already encrypted some data with the previous one.
+func (client *Client) SetEncoding(encoding Encoding) error {
+	if encoding != ByteEncoding && encoding != HashEncoding {
+		return errors.New("unknown encoding")
+	} else if encoding != HashEncoding && client.config.Curve != nil {
+		return errors.New("curves only support HashEncoding")
+	} else if len(client.plaintexts) > 0 {
+		return errors.New("data already encrypted, create a new instance")
+	}
+
+	client.encoding = encoding
+	return nil
+}
+
+// SetConcurrency function sets the maximum number of goroutines (workers) used
+// by the current client to encrypt, decrypt and compare batches of items, and
+// the number of consecutive items processed by each worker at once
+// (chunkSize). If workers is not positive, the client uses
+// runtime.GOMAXPROCS(0) workers (the default), and if chunkSize is not
+// positive, the client uses parallel.DefaultChunkSize. The output order of
+// every operation does not depend on these values.
+func (client *Client) SetConcurrency(workers, chunkSize int) {
+	client.workers, client.chunkSize = workers, chunkSize
+}
+
 // PubKey function returns the current client instance RSA public key byte slice
-// to be shared to the other client. It allows to share a common prime securely.
+// to be shared to the other client, generating the RSA key pair the first time
+// that it is called. It allows to share a common prime securely, but without
+// authenticating it, read more in ProposeParams. It returns an error if the
+// configuration disables RSA.
 func (client *Client) PubKey() ([]byte, error) {
 	if client.rsaKey == nil {
-		return nil, errors.New("client not initialized")
+		if client.config.RSABits <= 0 {
+			return nil, errors.New("RSA disabled by the configuration")
+		}
+
+		key, err := rsa.NewKey(client.config.RSABits)
+		if err != nil {
+			return nil, err
+		}
+		client.rsaKey = key
 	}
 
 	return client.rsaKey.PubKey()
@@ -41,40 +149,79 @@ func (client *Client) PubKey() ([]byte, error) {
 
 // GenEncryptedPrime function generates a common prime number to share with
 // other client and encrypts it with the RSA public key provided. It also try to
-// initialize the SRA key with the common prime generated.
+// initialize the client cipher with the common prime generated. The size of the
+// prime and the SRA exponent are defined by the client configuration, and the
+// RSA public key provided must be at least as large as the client one. If the
+// configuration defines a group, its safe prime is used as common prime, and if
+// it defines a curve, the prime of its field.
 func (client *Client) GenEncryptedPrime(extPubKey []byte) ([]byte, error) {
-	if len(extPubKey) == 0 {
+	return client.GenEncryptedPrimeContext(context.Background(), extPubKey)
+}
+
+// GenEncryptedPrimeContext function performs the same action as
+// GenEncryptedPrime but checking the context provided before start and before
+// storing the results, returning the context error if it is done. The client
+// is not modified if it fails.
+func (client *Client) GenEncryptedPrimeContext(ctx context.Context, extPubKey []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if len(extPubKey) == 0 {
 		return nil, errors.New("empty external public key")
-	} else if client.sraKey != nil && client.CommonPrime != nil {
+	} else if client.config.RSABits <= 0 {
+		return nil, errors.New("RSA disabled by the configuration")
+	} else if client.cipher != nil && client.CommonPrime != nil {
 		return nil, errors.New("common prime already defined, create a new instance")
 	}
 
-	var err error
-	var commonPrime *big.Int
-	if commonPrime, err = rand.Prime(rand.Reader, 256); err != nil {
+	extKey, err := rsa.ParsePubKey(extPubKey)
+	if err != nil {
 		return nil, err
+	} else if extKey.Size() < client.config.RSABits {
+		return nil, fmt.Errorf("external public key size %d below the configured %d", extKey.Size(), client.config.RSABits)
 	}
 
+	commonPrime, err := client.newCommonPrime()
+	if err != nil {
+		return nil, err
+	}
+
+	var cipher CommutativeCipher
 	var encryptedPrime []byte
 	var cpBytes []byte = []byte(commonPrime.Text(16))
-	if encryptedPrime, err = rsa.EncryptWitPubKey(extPubKey, cpBytes); err != nil {
+	if encryptedPrime, err = extKey.Encrypt(cpBytes); err != nil {
+		return nil, err
+	} else if cipher, err = client.newCipher(commonPrime); err != nil {
 		return nil, err
-	} else if client.sraKey, err = sra.NewKey(commonPrime, 32); err != nil {
+	} else if err = ctx.Err(); err != nil {
 		return nil, err
 	}
 
-	client.CommonPrime = commonPrime
+	client.cipher, client.CommonPrime = cipher, commonPrime
 	return encryptedPrime, nil
 }
 
 // SetEncryptedPrime function receives the common prime encrypted with the
 // current client public key, decrypts it with it private key and stores it into
 // the current client instance to request the intersection. It also initializes
-// the client SRA key with the received and decrypted common prime.
-func (client *Client) SetEncryptedPrime(encryptedPrime []byte) (err error) {
-	if len(encryptedPrime) == 0 {
+// the client cipher with the received and decrypted common prime. The prime
+// must have the size defined by the client configuration, or be the prime of
+// the configured group or curve if it is defined.
+func (client *Client) SetEncryptedPrime(encryptedPrime []byte) error {
+	return client.SetEncryptedPrimeContext(context.Background(), encryptedPrime)
+}
+
+// SetEncryptedPrimeContext function performs the same action as
+// SetEncryptedPrime but checking the context provided before start and before
+// storing the results, returning the context error if it is done. The client
+// is not modified if it fails.
+func (client *Client) SetEncryptedPrimeContext(ctx context.Context, encryptedPrime []byte) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	} else if len(encryptedPrime) == 0 {
 		return errors.New("empty encrypted prime")
-	} else if client.sraKey != nil && client.CommonPrime != nil {
+	} else if client.rsaKey == nil {
+		return errors.New("RSA key not defined")
+	} else if client.cipher != nil && client.CommonPrime != nil {
 		err = errors.New("common prime already defined, create a new instance")
 		return
 	}
@@ -85,117 +232,183 @@ func (client *Client) SetEncryptedPrime(encryptedPrime []byte) (err error) {
 		return
 	}
 
+	var cipher CommutativeCipher
 	var sCommonPrime string = string(encodedCommonPrime)
 	if commonPrime, ok := new(big.Int).SetString(sCommonPrime, 16); !ok {
 		err = errors.New("error decoding decrypted common prime")
-	} else if client.sraKey, err = sra.NewKey(commonPrime, 32); err == nil {
-		client.CommonPrime = commonPrime
+	} else if err = client.checkCommonPrime(commonPrime); err != nil {
+		return
+	} else if cipher, err = client.newCipher(commonPrime); err != nil {
+		return
+	} else if err = ctx.Err(); err == nil {
+		client.cipher, client.CommonPrime = cipher, commonPrime
 	}
 
 	return
 }
 
 // Encrypt function receives the data of the current client to encrypt it with
-// the SRA key. It iterates over all items enconding each item to big.Int and
-// encrypting it. Then returns the encrypted data.
-func (client *Client) Encrypt(data []string) (output [][]*big.Int, err error) {
+// the client cipher. It iterates over all items enconding each item to big.Int
+// and encrypting it, splitting the work between the client workers. Then
+// returns the encrypted data. If the client uses HashEncoding, each item is
+// encoded as a single group element and the client keeps the original item to
+// parse the intersection results.
+func (client *Client) Encrypt(data []string) ([][]*big.Int, error) {
+	return client.EncryptContext(context.Background(), data)
+}
+
+// EncryptContext function performs the same action as Encrypt but checking the
+// context provided between items, returning the context error if it is done.
+// The client is not modified if it fails.
+func (client *Client) EncryptContext(ctx context.Context, data []string) (output [][]*big.Int, err error) {
 	if data == nil || len(data) <= 0 {
 		return nil, errors.New("empty data")
-	} else if client.sraKey == nil {
+	} else if client.cipher == nil {
 		err = errors.New("common prime not defined")
 		return
 	}
 
+	// Keep the encoded elements to relate them with the original items if the
+	// client uses HashEncoding.
+	var encodedData [][]*big.Int = make([][]*big.Int, len(data))
 	output = make([][]*big.Int, len(data))
-	for i, item := range data {
-		var encoded []*big.Int = encoder.StrToInts(item)
-		var encrypted []*big.Int = make([]*big.Int, len(encoded))
-		for w, word := range encoded {
-			encrypted[w] = client.sraKey.Encrypt(word)
+	err = client.parallel(ctx, len(data), func(i int) (err error) {
+		if encodedData[i], err = client.encode(data[i]); err != nil {
+			return
 		}
-
-		output[i] = encrypted
+		output[i], err = client.encryptItem(encodedData[i])
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	if client.encoding == HashEncoding {
+		if client.plaintexts == nil {
+			client.plaintexts = make(map[string]string, len(data))
+		}
+		for i, encoded := range encodedData {
+			client.plaintexts[encoded[0].Text(16)] = data[i]
+		}
+	}
 	return
 }
 
 // EncryptExt functions allows to the current client to encrypt the encrypted
-// data of another client. It allows to the another client to perform the
+// data of another client, splitting the work between the client workers. It
+// allows to the another client to perform the
 // intersection using its re-encrypted data (the output) and, after re-encrypt
 // it, the current client encrypted data.
-func (client *Client) EncryptExt(input [][]*big.Int) (output [][]*big.Int, err error) {
+func (client *Client) EncryptExt(input [][]*big.Int) ([][]*big.Int, error) {
+	return client.EncryptExtContext(context.Background(), input)
+}
+
+// EncryptExtContext function performs the same action as EncryptExt but
+// checking the context provided between items, returning the context error if
+// it is done.
+func (client *Client) EncryptExtContext(ctx context.Context, input [][]*big.Int) (output [][]*big.Int, err error) {
 	if len(input) == 0 {
 		return nil, errors.New("empty input")
-	} else if client.sraKey == nil {
+	} else if client.cipher == nil {
 		return nil, errors.New("common prime not defined")
 	}
 
+	if err = client.validateItems(ctx, input); err != nil {
+		return nil, err
+	}
+
 	output = make([][]*big.Int, len(input))
 	// Iterate over input items and its words encrypting it.
-	for i, item := range input {
-		var encrypted []*big.Int = make([]*big.Int, len(item))
-		for w, word := range item {
-			encrypted[w] = client.sraKey.Encrypt(word)
-		}
-		output[i] = encrypted
+	err = client.parallel(ctx, len(input), func(i int) (err error) {
+		output[i], err = client.encryptItem(input[i])
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return
 }
 
 // PrepareIntersection function receives the current client re-encrypted data
 // (from another client) and creates a Bloom Filter with its content to be ready
-// to calculate the intersection.
+// to calculate the intersection. If the client uses ExactMatch or
+// CountingMatch, it creates a hash set or a counting Bloom filter instead (read
+// more in MatchMode). Once prepared, the intersection can be updated with
+// AddToIntersection and RemoveFromIntersection.
 func (client *Client) PrepareIntersection(encryptedData [][]*big.Int) error {
+	return client.PrepareIntersectionContext(context.Background(), encryptedData)
+}
+
+// PrepareIntersectionContext function performs the same action as
+// PrepareIntersection but checking the context provided between items,
+// returning the context error if it is done. The filter is not stored into the
+// client if it fails, so the intersection can be prepared again.
+func (client *Client) PrepareIntersectionContext(ctx context.Context, encryptedData [][]*big.Int) error {
 	if len(encryptedData) == 0 {
 		return errors.New("empty encrypted data")
+	} else if client.cipher == nil {
+		return errors.New("common prime not defined")
 	} else if client.filter != nil {
 		return errors.New("bloom filter already defined, create a new instance")
+	} else if err := client.validateItems(ctx, encryptedData); err != nil {
+		return err
 	}
 
-	// Initialize the filter.
-	client.filter = bloomfilter.NewFilter(len(encryptedData), 0.0001)
-
-	// Iterate over each encrypted data item flatting it into a single slice of
-	// bytes with the string representation of all of its words. Then adds the
-	// result to the initialized filter.
-	for _, item := range encryptedData {
-		var record []byte
-		for _, word := range item {
-			record = append(record, []byte(word.Text(16))...)
-		}
-		client.filter.Add(record)
+	// Initialize the filter and add the encrypted data to it.
+	var filter = client.newMembership(len(encryptedData))
+	if err := client.addRecords(ctx, filter, encryptedData); err != nil {
+		return err
 	}
 
+	client.filter = client.freezeMembership(filter)
 	return nil
 }
 
 // GetIntersection function allows to the current client to get the common items
 // with the data from another client. It receives the data re-encrypted by the
 // the encrypted data of the external client, re-encrypts it with the current
-// client SRA key and compares with the its own data using the bloom filter. It
-// returns the common data (only encrypted by the client to allow to it to
-// decrypt).
+// client cipher (splitting the work between the client workers) and compares
+// with the its own data using the filter (or the hash set if the client uses
+// ExactMatch). It returns the common data (only encrypted by the client to
+// allow to it to decrypt).
 func (client *Client) GetIntersection(input [][]*big.Int) ([][]*big.Int, error) {
+	return client.GetIntersectionContext(context.Background(), input)
+}
+
+// GetIntersectionContext function performs the same action as GetIntersection
+// but checking the context provided between items, returning the context error
+// if it is done.
+func (client *Client) GetIntersectionContext(ctx context.Context, input [][]*big.Int) ([][]*big.Int, error) {
 	if len(input) == 0 {
 		return nil, errors.New("empty input data")
-	} else if client.sraKey == nil {
+	} else if client.cipher == nil {
 		return nil, errors.New("common prime not defined")
 	} else if client.filter == nil {
 		return nil, errors.New("intersection not initialized")
+	} else if err := client.validateItems(ctx, input); err != nil {
+		return nil, err
 	}
 
-	var common [][]*big.Int
-	for _, item := range input {
-		var record []byte
-		for _, word := range item {
-			var encrypted *big.Int = client.sraKey.Encrypt(word)
-			record = append(record, []byte(encrypted.Text(16))...)
+	// Re-encrypt every item, flat it into a record and test it over the filter
+	// in parallel, then collect the matches in the input order.
+	var matches []bool = make([]bool, len(input))
+	var size int = client.cipher.Params().ElementSize
+	err := client.parallel(ctx, len(input), func(i int) error {
+		encrypted, err := client.encryptItem(input[i])
+		if err != nil {
+			return err
 		}
+		matches[i] = client.filter.Test(record(encrypted, size))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if client.filter.Test(record) {
-			common = append(common, item)
+	var common [][]*big.Int
+	for i, match := range matches {
+		if match {
+			common = append(common, input[i])
 		}
 	}
 
@@ -206,30 +419,208 @@ func (client *Client) GetIntersection(input [][]*big.Int) ([][]*big.Int, error)
 // result from another client. It returns an error if the common prime is not
 // defined or if the decoding process fails.
 func (client *Client) ParseIntersection(results [][]*big.Int) ([]string, error) {
+	return client.ParseIntersectionContext(context.Background(), results)
+}
+
+// ParseIntersectionContext function performs the same action as
+// ParseIntersection but checking the context provided between items, returning
+// the context error if it is done.
+func (client *Client) ParseIntersectionContext(ctx context.Context, results [][]*big.Int) ([]string, error) {
 	var err error
 	if len(results) == 0 {
 		return nil, errors.New("empty results data")
-	} else if client.sraKey == nil {
+	} else if client.cipher == nil {
 		err = errors.New("common prime not defined")
 		return nil, err
+	} else if err = client.validateItems(ctx, results); err != nil {
+		return nil, err
 	}
 
 	// Iterate over intersection result items and its words decrypting and
-	// decoding it.
+	// decoding it, splitting the work between the client workers.
 	var output []string = make([]string, len(results))
-	for i, item := range results {
-		var decrypted []*big.Int = make([]*big.Int, len(item))
-		for w, word := range item {
-			decrypted[w] = client.sraKey.Decrypt(word)
+	err = client.parallel(ctx, len(results), func(i int) (err error) {
+		var decrypted []*big.Int = make([]*big.Int, len(results[i]))
+		for w, word := range results[i] {
+			if decrypted[w] = client.cipher.Decrypt(word); decrypted[w] == nil {
+				return errors.New("invalid element in the intersection results")
+			}
 		}
 
-		var decoded string = encoder.IntsToStr(decrypted)
-		// if decoded, err = encoder.IntsToStr(decrypted); err != nil {
-		// 	return nil, err
-		// }
-
-		output[i] = decoded
+		output[i], err = client.decode(decrypted)
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return output, nil
 }
+
+// Codec function returns a wire.Codec to encode and decode the encrypted items
+// of the current client, sized and validated according to the parameters of
+// the client cipher. It returns an error if the common prime is not defined.
+func (client *Client) Codec() (*wire.Codec, error) {
+	if client.cipher == nil {
+		return nil, errors.New("common prime not defined")
+	}
+
+	var params CipherParams = client.cipher.Params()
+	codec, err := wire.NewCodec(params.Prime)
+	if err != nil {
+		return nil, err
+	}
+	codec.Size, codec.Validate = params.ElementSize, client.cipher.ValidateElement
+	return codec, nil
+}
+
+// CipherParams function returns the public parameters of the client cipher. It
+// returns an error if the common prime is not defined.
+func (client *Client) CipherParams() (CipherParams, error) {
+	if client.cipher == nil {
+		return CipherParams{}, errors.New("common prime not defined")
+	}
+	return client.cipher.Params(), nil
+}
+
+// addRecords function adds every item provided to the filter, checking the
+// context provided between items and returning its error if it is done, or
+// the filter error if any item cannot be added.
+func (client *Client) addRecords(ctx context.Context, filter membership.Filter, items [][]*big.Int) error {
+	var size int = client.cipher.Params().ElementSize
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		} else if err = filter.Add(record(item, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record function flats the encrypted item provided into its canonical
+// representation, a single slice of bytes with the fixed-width big-endian
+// encoding of all of its words (with the element size of the client cipher
+// provided), to be added to or tested over the filter. The size is provided
+// by the callers, which get it once per batch from the cipher parameters.
+func record(item []*big.Int, size int) []byte {
+	var result []byte = make([]byte, len(item)*size)
+	for w, word := range item {
+		word.FillBytes(result[w*size : (w+1)*size])
+	}
+	return result
+}
+
+// encryptItem function encrypts every word of the item provided with the
+// client cipher. It returns an error if some word is not valid for the cipher.
+func (client *Client) encryptItem(item []*big.Int) ([]*big.Int, error) {
+	var encrypted []*big.Int = make([]*big.Int, len(item))
+	for w, word := range item {
+		if encrypted[w] = client.cipher.Encrypt(word); encrypted[w] == nil {
+			return nil, errors.New("invalid element")
+		}
+	}
+	return encrypted, nil
+}
+
+// parallel function calls fn for every index of the range [0, n) using the
+// client workers and chunk size.
+func (client *Client) parallel(ctx context.Context, n int, fn func(i int) error) error {
+	return parallel.Run(ctx, n, client.workers, client.chunkSize, fn)
+}
+
+// newCommonPrime function returns the common prime defined by the client
+// configuration: the prime of the field of the curve, the safe prime of the
+// group or a new random prime of the configured size.
+func (client *Client) newCommonPrime() (*big.Int, error) {
+	if client.config.Curve != nil {
+		return client.config.Curve.Params().P, nil
+	} else if client.config.Group != nil {
+		return client.config.Group.P, nil
+	}
+	return rand.Prime(rand.Reader, client.config.PrimeBits)
+}
+
+// checkCommonPrime function checks that the common prime provided by the other
+// client matches the client configuration: it must be the prime of the
+// configured curve or group if they are defined, or a prime of the configured
+// size otherwise.
+func (client *Client) checkCommonPrime(commonPrime *big.Int) error {
+	var group, curve = client.config.Group, client.config.Curve
+	if group != nil && commonPrime.Cmp(group.P) != 0 {
+		return fmt.Errorf("common prime does not match the configured group %s", group.Name)
+	} else if curve != nil && commonPrime.Cmp(curve.Params().P) != 0 {
+		return fmt.Errorf("common prime does not match the configured curve %s", curve.Params().Name)
+	} else if commonPrime.BitLen() != client.config.PrimeBits {
+		return fmt.Errorf("common prime size %d does not match the configured %d", commonPrime.BitLen(), client.config.PrimeBits)
+	} else if group == nil && curve == nil && !commonPrime.ProbablyPrime(20) {
+		return errors.New("common prime is not prime")
+	}
+	return nil
+}
+
+// newCipher function instances the client cipher for the common prime
+// provided using the client CipherFactory. If it is not defined, the cipher is
+// an elliptic curve one if the configuration defines a curve, otherwise it is
+// an SRA one. If the configuration defines a group, the SRA cipher works into
+// its subgroup of quadratic residues.
+func (client *Client) newCipher(commonPrime *big.Int) (CommutativeCipher, error) {
+	if client.factory != nil {
+		return client.factory(commonPrime)
+	} else if client.config.Curve != nil {
+		return ECFactory()(commonPrime)
+	}
+	return SRAFactory(client.config.Group, client.config.ExponentBits)(commonPrime)
+}
+
+// encode function encodes the item provided into a slice of elements of the
+// client cipher according to the current client encoding. If the client uses
+// ByteEncoding, every byte is shifted by byteOffset first, so any byte,
+// including the control ones, can be encoded.
+func (client *Client) encode(item string) ([]*big.Int, error) {
+	if client.encoding == HashEncoding {
+		element, err := client.cipher.HashElement([]byte(item))
+		if err != nil {
+			return nil, err
+		}
+		return []*big.Int{element}, nil
+	}
+
+	var encoded []*big.Int = encoder.StrToInts(item)
+	for i, char := range encoded {
+		var err error
+		char.Add(char, big.NewInt(byteOffset))
+		if encoded[i], err = client.cipher.EncodeElement(char); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+// decode function decodes the decrypted elements provided into the original
+// item according to the current client encoding. If the client uses
+// HashEncoding, it looks for the original item between the encrypted ones,
+// returning an error if it is not found. Otherwise, it undoes the shift of
+// every byte, returning an error if the result is not a byte.
+func (client *Client) decode(decrypted []*big.Int) (string, error) {
+	if client.encoding != HashEncoding {
+		for i, char := range decrypted {
+			var err error
+			if decrypted[i], err = client.cipher.DecodeElement(char); err != nil {
+				return "", err
+			}
+			decrypted[i].Sub(decrypted[i], big.NewInt(byteOffset))
+			if decrypted[i].Sign() < 0 || decrypted[i].Cmp(big.NewInt(0xff)) > 0 {
+				return "", errors.New("decoded element out of the range of a byte")
+			}
+		}
+		return encoder.IntsToStr(decrypted), nil
+	}
+
+	if len(decrypted) == 1 {
+		if item, ok := client.plaintexts[decrypted[0].Text(16)]; ok {
+			return item, nil
+		}
+	}
+	return "", errors.New("unknown item in the intersection results")
+}
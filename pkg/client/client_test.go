@@ -1,8 +1,10 @@
 package client
 
 import (
+	"context"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -137,7 +139,7 @@ func TestEncryptExt(t *testing.T) {
 		var decrypted []*big.Int = make([]*big.Int, len(item))
 
 		for w, word := range item {
-			decrypted[w] = clientB.sraKey.Decrypt(word)
+			decrypted[w] = clientB.cipher.Decrypt(word)
 		}
 		result[i] = decrypted
 	}
@@ -250,3 +252,198 @@ func TestParseIntersection(t *testing.T) {
 		t.Fatalf("expected %v, got %v", input, output)
 	}
 }
+
+func TestParseIntersectionNUL(t *testing.T) {
+	var input = []string{"hello\x00world", "\x00"}
+	clientA, clientB := agreedPair(t)
+
+	encInputByA, _ := clientA.Encrypt(input)
+	encInputByB, err := clientB.Encrypt(input)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	encInputByAB, _ := clientB.EncryptExt(encInputByA)
+	clientA.PrepareIntersection(encInputByAB)
+
+	result, _ := clientA.GetIntersection(encInputByB)
+	if output, err := clientB.ParseIntersection(result); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(input, output) {
+		t.Fatalf("expected %q, got %q", input, output)
+	}
+}
+
+func TestControlBytes(t *testing.T) {
+	var item []byte = make([]byte, 256)
+	for i := range item {
+		item[i] = byte(i)
+	}
+
+	clientA, clientB := agreedPair(t)
+	if encoded, err := clientA.encode(string(item)); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if decoded, err := clientA.decode(encoded); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if decoded != string(item) {
+		t.Fatalf("expected %q, got %q", item, decoded)
+	}
+
+	var input = []string{"\x01", "\x1f\x7f", "\xfe\xff", string(item)}
+	encInputByA, _ := clientA.Encrypt(input)
+	encInputByB, err := clientB.Encrypt(input)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	encInputByAB, _ := clientB.EncryptExt(encInputByA)
+	clientA.PrepareIntersection(encInputByAB)
+
+	result, _ := clientA.GetIntersection(encInputByB)
+	if output, err := clientB.ParseIntersection(result); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(input, output) {
+		t.Fatalf("expected %q, got %q", input, output)
+	}
+}
+
+func TestHashEncoding(t *testing.T) {
+	var inputA = []string{"hello world", "foo", "bar"}
+	var inputB = []string{"bar", "hello world", "baz"}
+
+	clientA, _ := Init()
+	clientB, _ := Init()
+	if err := clientA.SetEncoding(Encoding(-1)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	clientA.SetEncoding(HashEncoding)
+	clientB.SetEncoding(HashEncoding)
+
+	pubKey, _ := clientB.PubKey()
+	encPrime, _ := clientA.GenEncryptedPrime(pubKey)
+	clientB.SetEncryptedPrime(encPrime)
+
+	encInputByA, _ := clientA.Encrypt(inputA)
+	encInputByB, err := clientB.Encrypt(inputB)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	for _, item := range encInputByB {
+		if len(item) != 1 {
+			t.Fatalf("expected single element per item, got %d", len(item))
+		}
+	}
+	if err := clientB.SetEncoding(ByteEncoding); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if _, err := clientB.EncryptExt([][]*big.Int{{big.NewInt(2), big.NewInt(3)}}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	encInputByAB, _ := clientB.EncryptExt(encInputByA)
+	clientA.PrepareIntersection(encInputByAB)
+
+	result, err := clientA.GetIntersection(encInputByB)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// The bloom filter could include false positives, so check that the
+	// common items are included into the results.
+	expected := []string{"bar", "hello world"}
+	if output, err := clientB.ParseIntersection(result); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if len(output) < len(expected) || !reflect.DeepEqual(expected, output[:len(expected)]) {
+		t.Fatalf("expected %v, got %v", expected, output)
+	} else if _, err := clientA.ParseIntersection(result); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestContext(t *testing.T) {
+	var input = []string{"hello world"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	clientA, _ := Init()
+	clientB, _ := Init()
+	clientA.SetEncoding(HashEncoding)
+	clientB.SetEncoding(HashEncoding)
+
+	pubKey, _ := clientB.PubKey()
+	if _, err := clientA.GenEncryptedPrimeContext(ctx, pubKey); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	} else if clientA.CommonPrime != nil {
+		t.Fatal("expected nil, got common prime")
+	}
+	encPrime, _ := clientA.GenEncryptedPrime(pubKey)
+	if err := clientB.SetEncryptedPrimeContext(ctx, encPrime); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	clientB.SetEncryptedPrime(encPrime)
+
+	if _, err := clientA.EncryptContext(ctx, input); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	} else if len(clientA.plaintexts) != 0 {
+		t.Fatalf("expected no plaintexts, got %d", len(clientA.plaintexts))
+	}
+	encInputByA, _ := clientA.Encrypt(input)
+	encInputByB, _ := clientB.Encrypt(input)
+
+	if _, err := clientB.EncryptExtContext(ctx, encInputByA); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	encInputByAB, _ := clientB.EncryptExt(encInputByA)
+
+	if err := clientA.PrepareIntersectionContext(ctx, encInputByAB); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	} else if err := clientA.PrepareIntersection(encInputByAB); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	if _, err := clientA.GetIntersectionContext(ctx, encInputByB); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	result, _ := clientA.GetIntersection(encInputByB)
+
+	if _, err := clientB.ParseIntersectionContext(ctx, result); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	} else if output, _ := clientB.ParseIntersection(result); !reflect.DeepEqual(input, output) {
+		t.Fatalf("expected %v, got %v", input, output)
+	}
+}
+
+func TestSetConcurrency(t *testing.T) {
+	var input []string = make([]string, 100)
+	for i := range input {
+		input[i] = strings.Repeat("a", i%10) + string(rune('a'+i%26))
+	}
+
+	clientA, _ := Init(WithEncoding(HashEncoding))
+	clientB, _ := Init(WithEncoding(HashEncoding))
+	pubKey, _ := clientB.PubKey()
+	encPrime, _ := clientA.GenEncryptedPrime(pubKey)
+	clientB.SetEncryptedPrime(encPrime)
+
+	clientA.SetConcurrency(1, 0)
+	sequential, _ := clientA.Encrypt(input)
+	clientA.SetConcurrency(8, 3)
+	concurrent, err := clientA.Encrypt(input)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(sequential, concurrent) {
+		t.Fatal("expected same output with different concurrency")
+	}
+
+	clientB.SetConcurrency(8, 3)
+	encInputByAB, _ := clientB.EncryptExt(concurrent)
+	clientA.PrepareIntersection(encInputByAB)
+	encInputByB, _ := clientB.Encrypt(input)
+	result, _ := clientA.GetIntersection(encInputByB)
+	if output, err := clientB.ParseIntersection(result); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(input, output) {
+		t.Fatalf("expected %v, got %v", input, output)
+	}
+}
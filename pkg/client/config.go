@@ -0,0 +1,249 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+// Config struct contains the security parameters of a Client: the size in bits
+// of the RSA key used to share the common prime (RSABits, or zero to disable
+// RSA and agree the common prime only with ProposeParams, the key is only
+// generated when Client.PubKey is called), the size in bits of
+// the common prime (PrimeBits), the size in bits of the SRA secret exponent
+// (ExponentBits) and the well-known safe prime group used instead of a random
+// common prime (Group). If Group is nil, a random prime of PrimeBits is
+// generated. Both clients must use the same PrimeBits and Group to agree the
+// common prime. If Curve is defined, the clients use the elliptic curve
+// commutative encryption of the ec package instead of SRA, and the prime of
+// the curve field as common prime.
+type Config struct {
+	RSABits      int
+	PrimeBits    int
+	ExponentBits int
+	Group        *sra.Group
+	Curve        elliptic.Curve
+}
+
+var (
+	// Security112 preset provides 112 bits of security, according to the NIST
+	// SP 800-57 recommendations for finite field cryptography, using the
+	// ffdhe2048 group.
+	Security112 = Config{RSABits: 2048, PrimeBits: 2048, ExponentBits: 224, Group: sra.FFDHE2048}
+	// Security128 preset provides 128 bits of security, according to the NIST
+	// SP 800-57 recommendations for finite field cryptography, using the
+	// ffdhe3072 group.
+	Security128 = Config{RSABits: 3072, PrimeBits: 3072, ExponentBits: 256, Group: sra.FFDHE3072}
+	// DefaultConfig contains the configuration used by Init if no other is
+	// provided.
+	DefaultConfig = Security112
+	// MinimumConfig contains the minimum values accepted for every parameter
+	// of the configuration.
+	MinimumConfig = Security112
+)
+
+// Validate function checks that every parameter of the current configuration
+// reaches the MinimumConfig values, that the exponent is smaller than the
+// common prime and that the group, if it is defined, has the prime size. If
+// the configuration defines a curve, it checks that it is supported (only
+// P-256 is) and that the prime and exponent sizes match it instead.
+func (cfg Config) Validate() error {
+	if cfg.Curve != nil {
+		return cfg.validateCurve()
+	}
+
+	switch {
+	case cfg.RSABits < 0:
+		return fmt.Errorf("negative RSA key size %d", cfg.RSABits)
+	case cfg.RSABits != 0 && cfg.RSABits < MinimumConfig.RSABits:
+		return fmt.Errorf("RSA key size %d below the minimum %d", cfg.RSABits, MinimumConfig.RSABits)
+	case cfg.PrimeBits < MinimumConfig.PrimeBits:
+		return fmt.Errorf("prime size %d below the minimum %d", cfg.PrimeBits, MinimumConfig.PrimeBits)
+	case cfg.ExponentBits < MinimumConfig.ExponentBits:
+		return fmt.Errorf("exponent size %d below the minimum %d", cfg.ExponentBits, MinimumConfig.ExponentBits)
+	case cfg.ExponentBits >= cfg.PrimeBits-1:
+		return errors.New("exponent size must be smaller than the prime size")
+	case cfg.Group != nil && cfg.Group.P.BitLen() != cfg.PrimeBits:
+		return fmt.Errorf("group %s does not match the prime size %d", cfg.Group.Name, cfg.PrimeBits)
+	}
+	return nil
+}
+
+// validateCurve function checks the configuration parameters when it defines a
+// curve.
+func (cfg Config) validateCurve() error {
+	var params = cfg.Curve.Params()
+	switch {
+	case cfg.RSABits < 0:
+		return fmt.Errorf("negative RSA key size %d", cfg.RSABits)
+	case cfg.RSABits != 0 && cfg.RSABits < MinimumConfig.RSABits:
+		return fmt.Errorf("RSA key size %d below the minimum %d", cfg.RSABits, MinimumConfig.RSABits)
+	case params.Name != elliptic.P256().Params().Name:
+		return fmt.Errorf("unsupported curve %s", params.Name)
+	case cfg.Group != nil:
+		return errors.New("group and curve cannot be defined together")
+	case cfg.PrimeBits != params.BitSize || cfg.ExponentBits != params.N.BitLen():
+		return fmt.Errorf("prime and exponent sizes do not match the curve %s", params.Name)
+	}
+	return nil
+}
+
+// Option type defines a function that configures a Client during its
+// initialization.
+type Option func(client *Client) error
+
+// WithConfig function returns an Option that sets the configuration provided,
+// such as one of the presets.
+func WithConfig(cfg Config) Option {
+	return func(client *Client) error {
+		client.config = cfg
+		return nil
+	}
+}
+
+// WithRSABits function returns an Option that sets the size in bits of the
+// client RSA key, or disables it if it is zero.
+func WithRSABits(bits int) Option {
+	return func(client *Client) error {
+		client.config.RSABits = bits
+		return nil
+	}
+}
+
+// WithPrimeBits function returns an Option that sets the size in bits of a
+// random common prime, removing the configured group or curve.
+func WithPrimeBits(bits int) Option {
+	return func(client *Client) error {
+		client.config.PrimeBits = bits
+		client.config.Group, client.config.Curve = nil, nil
+		return nil
+	}
+}
+
+// WithGroup function returns an Option that sets the well-known safe prime
+// group provided (such as sra.FFDHE3072) and its prime size, removing the
+// configured curve.
+func WithGroup(group *sra.Group) Option {
+	return func(client *Client) error {
+		if group == nil {
+			return errors.New("undefined group")
+		}
+
+		client.config.Group, client.config.Curve = group, nil
+		client.config.PrimeBits = group.P.BitLen()
+		return nil
+	}
+}
+
+// WithCurve function returns an Option that sets the elliptic curve provided
+// (only elliptic.P256 is supported) and the prime and exponent sizes of it,
+// removing the configured group. The client also uses HashEncoding.
+func WithCurve(curve elliptic.Curve) Option {
+	return func(client *Client) error {
+		if curve == nil {
+			return errors.New("undefined curve")
+		}
+
+		var params = curve.Params()
+		client.config.Curve = curve
+		client.config.Group = nil
+		client.config.PrimeBits, client.config.ExponentBits = params.BitSize, params.N.BitLen()
+		client.encoding = HashEncoding
+		return nil
+	}
+}
+
+// WithExponentBits function returns an Option that sets the size in bits of
+// the client SRA secret exponent.
+func WithExponentBits(bits int) Option {
+	return func(client *Client) error {
+		client.config.ExponentBits = bits
+		return nil
+	}
+}
+
+// WithCipher function returns an Option that sets the CipherFactory used by the
+// client to instance its cipher once the common prime is agreed, instead of
+// the SRA or elliptic curve ones defined by the configuration. The common prime
+// is still generated and checked according to the configuration, so the
+// factory must support it.
+func WithCipher(factory CipherFactory) Option {
+	return func(client *Client) error {
+		if factory == nil {
+			return errors.New("undefined cipher factory")
+		}
+
+		client.factory = factory
+		return nil
+	}
+}
+
+// WithIdentity function returns an Option that sets the Ed25519 private key
+// that identifies the client during the parameters agreement, instead of a new
+// random one.
+func WithIdentity(identity ed25519.PrivateKey) Option {
+	return func(client *Client) error {
+		if len(identity) != ed25519.PrivateKeySize {
+			return errors.New("invalid identity")
+		}
+
+		client.identity = identity
+		return nil
+	}
+}
+
+// WithPeerIdentity function returns an Option that sets the Ed25519 public key
+// of the other client, so the parameters agreement fails if its messages are
+// not signed by it. The parameters agreement requires it, unless the client is
+// initialized with WithAnyPeer.
+func WithPeerIdentity(peer ed25519.PublicKey) Option {
+	return func(client *Client) error {
+		if len(peer) != ed25519.PublicKeySize {
+			return errors.New("invalid peer identity")
+		}
+
+		client.trustedPeer = peer
+		return nil
+	}
+}
+
+// WithAnyPeer function returns an Option that allows the parameters agreement
+// with any other client, accepting the messages signed by any identity if no
+// one is set by WithPeerIdentity. Then, the parameters are not
+// authenticated, so an attacker in the middle can replace them, unless the
+// agreed Client.PeerIdentity is checked before trusting them. It is intended
+// for servers that authenticate themselves to unknown clients.
+func WithAnyPeer() Option {
+	return func(client *Client) error {
+		client.anyPeer = true
+		return nil
+	}
+}
+
+// WithEncoding function returns an Option that sets the client encoding (read
+// more in Client.SetEncoding).
+func WithEncoding(encoding Encoding) Option {
+	return func(client *Client) error {
+		return client.SetEncoding(encoding)
+	}
+}
+
+// WithMatchMode function returns an Option that sets the client match mode
+// (read more in Client.SetMatchMode).
+func WithMatchMode(mode MatchMode) Option {
+	return func(client *Client) error {
+		return client.SetMatchMode(mode)
+	}
+}
+
+// WithConcurrency function returns an Option that sets the client concurrency
+// (read more in Client.SetConcurrency).
+func WithConcurrency(workers, chunkSize int) Option {
+	return func(client *Client) error {
+		client.SetConcurrency(workers, chunkSize)
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"crypto/elliptic"
+	"reflect"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []Config{Security112, Security128} {
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+	}
+
+	var invalid = []Config{
+		{RSABits: 1024, PrimeBits: 2048, ExponentBits: 224},
+		{RSABits: -1, PrimeBits: 2048, ExponentBits: 224},
+		{RSABits: 2048, PrimeBits: 256, ExponentBits: 224},
+		{RSABits: 2048, PrimeBits: 2048, ExponentBits: 32},
+		{RSABits: 2048, PrimeBits: 2048, ExponentBits: 2048},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected error for %+v, got nil", cfg)
+		} else if _, err := Init(WithConfig(cfg)); err == nil {
+			t.Fatalf("expected error for %+v, got nil", cfg)
+		}
+	}
+}
+
+func TestInitOptions(t *testing.T) {
+	client, err := Init(
+		WithRSABits(3072),
+		WithPrimeBits(2560),
+		WithExponentBits(256),
+		WithEncoding(HashEncoding),
+		WithConcurrency(2, 16),
+	)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	var expected = Config{RSABits: 3072, PrimeBits: 2560, ExponentBits: 256}
+	if cfg := client.Config(); cfg != expected {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	} else if client.rsaKey != nil {
+		t.Fatal("expected RSA key generated on demand, got key")
+	} else if _, err = client.PubKey(); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if client.rsaKey.Size() != 3072 {
+		t.Fatalf("expected 3072, got %d", client.rsaKey.Size())
+	} else if client.encoding != HashEncoding || client.workers != 2 || client.chunkSize != 16 {
+		t.Fatal("expected options applied")
+	}
+
+	if _, err := Init(WithEncoding(Encoding(-1))); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestConfigAgreement(t *testing.T) {
+	// The responder rejects RSA public keys smaller than its own.
+	initiator, _ := Init()
+	responder, _ := Init(WithRSABits(3072))
+	pubKey, _ := initiator.PubKey()
+	if _, err := responder.GenEncryptedPrime(pubKey); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// The initiator rejects primes with a different size than its own.
+	initiator, _ = Init(WithPrimeBits(2560))
+	responder, _ = Init()
+	pubKey, _ = initiator.PubKey()
+	encPrime, err := responder.GenEncryptedPrime(pubKey)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if responder.CommonPrime.BitLen() != DefaultConfig.PrimeBits {
+		t.Fatalf("expected %d, got %d", DefaultConfig.PrimeBits, responder.CommonPrime.BitLen())
+	} else if err = initiator.SetEncryptedPrime(encPrime); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if initiator.CommonPrime != nil {
+		t.Fatal("expected nil, got common prime")
+	}
+}
+
+func TestConfigGroup(t *testing.T) {
+	if _, err := Init(WithGroup(nil)); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err := Init(WithConfig(Config{RSABits: 2048, PrimeBits: 3072, ExponentBits: 224, Group: sra.FFDHE2048})); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Both clients must agree the same group.
+	initiator, _ := Init()
+	responder, _ := Init(WithGroup(sra.MODP2048))
+	pubKey, _ := initiator.PubKey()
+	encPrime, err := responder.GenEncryptedPrime(pubKey)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if responder.CommonPrime.Cmp(sra.MODP2048.P) != 0 {
+		t.Fatal("expected group prime as common prime")
+	} else if err = initiator.SetEncryptedPrime(encPrime); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Every encrypted element belongs to the group subgroup with both
+	// encodings.
+	for _, encoding := range []Encoding{ByteEncoding, HashEncoding} {
+		initiator, _ = Init(WithGroup(sra.MODP2048), WithEncoding(encoding))
+		pubKey, _ = initiator.PubKey()
+		responder, _ = Init(WithGroup(sra.MODP2048), WithEncoding(encoding))
+		encPrime, _ = responder.GenEncryptedPrime(pubKey)
+		if err = initiator.SetEncryptedPrime(encPrime); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+
+		var input = []string{"hello world"}
+		encrypted, _ := initiator.Encrypt(input)
+		for _, word := range encrypted[0] {
+			if !sra.MODP2048.Contains(word) {
+				t.Fatalf("expected element into the subgroup, got %s", word)
+			}
+		}
+		reEncrypted, _ := responder.EncryptExt(encrypted)
+		initiator.PrepareIntersection(reEncrypted)
+		encByResponder, _ := responder.Encrypt(input)
+		common, _ := initiator.GetIntersection(encByResponder)
+		if result, err := responder.ParseIntersection(common); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if !reflect.DeepEqual(input, result) {
+			t.Fatalf("expected %v, got %v", input, result)
+		}
+	}
+}
+
+func TestConfigCurve(t *testing.T) {
+	if _, err := Init(WithCurve(nil)); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err := Init(WithCurve(elliptic.P384())); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err := Init(WithCurve(elliptic.P256()), WithEncoding(ByteEncoding)); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if _, err := Init(WithCurve(elliptic.P256()), WithRSABits(-1)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Both clients must agree the same curve.
+	initiator, _ := Init()
+	responder, _ := Init(WithCurve(elliptic.P256()))
+	pubKey, _ := initiator.PubKey()
+	encPrime, err := responder.GenEncryptedPrime(pubKey)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if responder.CommonPrime.Cmp(elliptic.P256().Params().P) != 0 {
+		t.Fatal("expected curve prime as common prime")
+	} else if err = initiator.SetEncryptedPrime(encPrime); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	initiator, _ = Init(WithCurve(elliptic.P256()))
+	responder, _ = Init(WithCurve(elliptic.P256()))
+	pubKey, _ = initiator.PubKey()
+	encPrime, _ = responder.GenEncryptedPrime(pubKey)
+	if err = initiator.SetEncryptedPrime(encPrime); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// Encrypted items are encoded points that the codec can share.
+	var input = []string{"hello world", "foo"}
+	encrypted, err := initiator.Encrypt(input)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	codec, _ := initiator.Codec()
+	data, err := codec.Marshal(encrypted)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if encrypted, err = codec.Unmarshal(data); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	reEncrypted, _ := responder.EncryptExt(encrypted)
+	initiator.PrepareIntersection(reEncrypted)
+	encByResponder, _ := responder.Encrypt([]string{"bar", "hello world"})
+	common, _ := initiator.GetIntersection(encByResponder)
+	// The bloom filter could include false positives, so only check that the
+	// common item is the last one of the results.
+	if result, err := responder.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if len(result) == 0 || result[len(result)-1] != "hello world" {
+		t.Fatalf("expected [hello world], got %v", result)
+	}
+}
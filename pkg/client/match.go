@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+	"github.com/lucasmenendez/gopsi/pkg/cuckoofilter"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+// MatchMode type defines how the Client stores its re-encrypted items to
+// compare them with the items of the other client and get the intersection.
+type MatchMode int
+
+const (
+	// FilterMatch stores the re-encrypted items into a Bloom filter with a
+	// false positive rate of 0.0001, which requires around 19.2 bits (2.4
+	// bytes) per item with the optimal filter size, regardless of the element
+	// size. The intersection can include items that are not into the set of
+	// the other client with that probability per tested item. It is the
+	// default mode.
+	FilterMatch MatchMode = iota
+	// ExactMatch stores the canonical re-encrypted items into a hash set, so
+	// the intersection never includes false positives. It requires the
+	// encoded size of each item (the element size of the cipher per word)
+	// plus the hash set overhead (around 50 bytes) per item: for hash encoded
+	// items, around 306 bytes for ffdhe2048, 434 bytes for ffdhe3072 and 83
+	// bytes for P-256, more than 30 times the filter size.
+	ExactMatch
+	// CountingMatch stores the re-encrypted items into a counting Bloom filter
+	// with the same false positive rate as FilterMatch, which supports removing
+	// items after the intersection is prepared (read more in
+	// Client.RemoveFromIntersection). It requires four times the memory of
+	// FilterMatch, around 9.6 bytes per item.
+	CountingMatch
+	// CuckooMatch stores the re-encrypted items into a cuckoo filter with the
+	// same false positive rate as FilterMatch, which supports removing items
+	// as CountingMatch does and requires around 17.9 bits (2.2 bytes) per item,
+	// less than FilterMatch. Adding items beyond the number of items that the
+	// intersection was prepared for can fail when the filter gets full.
+	CuckooMatch
+	// ScalableMatch stores the re-encrypted items into a scalable Bloom filter
+	// that grows as items are added, keeping the false positive rate of
+	// FilterMatch regardless of the number of items. It allows preparing the
+	// intersection from a stream without knowing the number of items in
+	// advance (read more in Client.PrepareIntersectionStream), and adding more
+	// items than the ones that it was prepared for. It requires slightly more
+	// memory than FilterMatch, up to around twice if the number of items is
+	// unknown.
+	ScalableMatch
+	// BlockedMatch stores the re-encrypted items into a blocked Bloom filter
+	// with the same false positive rate as FilterMatch, which sets every
+	// position of an item into a single cache line, so testing an item
+	// requires a single memory access. It speeds up the intersection of large
+	// sets, whose filters do not fit into the CPU cache, and requires around
+	// 22 bits (2.8 bytes) per item, 15% more than FilterMatch.
+	BlockedMatch
+	// GolombMatch stores the re-encrypted items into a Golomb-coded set with
+	// the same false positive rate as FilterMatch, a compact and read-only form
+	// of the canonical records that requires around 14.8 bits (1.9 bytes) per
+	// item, 20% less than FilterMatch, to be sent to another party. The items
+	// are collected into a hash set while the intersection is prepared, so the
+	// intersection cannot be updated once prepared, and testing an item is
+	// several times slower than with FilterMatch.
+	GolombMatch
+)
+
+// exactSet type implements membership.Filter with a hash set of the items,
+// without false positives.
+type exactSet map[string]struct{}
+
+// Add function stores the items provided into the set.
+func (set exactSet) Add(items ...[]byte) error {
+	for _, item := range items {
+		set[string(item)] = struct{}{}
+	}
+	return nil
+}
+
+// Remove function deletes the items provided from the set.
+func (set exactSet) Remove(items ...[]byte) error {
+	for _, item := range items {
+		delete(set, string(item))
+	}
+	return nil
+}
+
+// Test function returns if the item provided is into the set.
+func (set exactSet) Test(item []byte) bool {
+	_, ok := set[string(item)]
+	return ok
+}
+
+// MarshalBinary function returns membership.ErrUnsupported, because the set
+// is only used locally.
+func (set exactSet) MarshalBinary() ([]byte, error) {
+	return nil, membership.ErrUnsupported
+}
+
+// SetMatchMode function sets the mode used by the current client to compare
+// its re-encrypted items with the items of the other client (read more in
+// MatchMode). It returns an error if the mode is not supported or if the
+// intersection is already prepared.
+func (client *Client) SetMatchMode(mode MatchMode) error {
+	if mode < FilterMatch || mode > GolombMatch {
+		return errors.New("unknown match mode")
+	} else if client.filter != nil {
+		return errors.New("intersection already prepared, create a new instance")
+	}
+
+	client.match = mode
+	return nil
+}
+
+// newMembership function instances the membership filter of the current
+// client match mode for the number of items provided. The scalable filters
+// use DefaultBatchSize as initial size if the number of items is not positive.
+// The clients that use GolombMatch collect the items into a hash set, that
+// must be converted with freezeMembership once every item is added.
+func (client *Client) newMembership(size int) membership.Filter {
+	switch client.match {
+	case ExactMatch, GolombMatch:
+		return make(exactSet, size)
+	case CountingMatch:
+		return bloomfilter.NewCountingFilter(size, filterFPRate)
+	case CuckooMatch:
+		return cuckoofilter.NewFilter(size, filterFPRate)
+	case ScalableMatch:
+		if size <= 0 {
+			size = DefaultBatchSize
+		}
+		return bloomfilter.NewScalableFilter(size, filterFPRate)
+	case BlockedMatch:
+		return bloomfilter.NewBlockedFilter(size, filterFPRate)
+	}
+	return bloomfilter.NewFilter(size, filterFPRate)
+}
+
+// freezeMembership function returns the Golomb-coded set of the records of
+// the hash set provided if the client uses GolombMatch, or the filter provided
+// otherwise.
+func (client *Client) freezeMembership(filter membership.Filter) membership.Filter {
+	set, ok := filter.(exactSet)
+	if !ok || client.match != GolombMatch {
+		return filter
+	}
+
+	var records [][]byte = make([][]byte, 0, len(set))
+	for record := range set {
+		records = append(records, []byte(record))
+	}
+	return bloomfilter.NewGolombSet(records, filterFPRate)
+}
+
+// AddToIntersection function adds the current client re-encrypted items
+// provided (from another client) to the prepared intersection, without
+// preparing it again. The filter keeps the size of the first preparation, so
+// its false positive rate grows if it contains more items than the ones that
+// it was prepared for, and the cuckoo filters can get full, unless the client
+// uses ScalableMatch. It returns an error if the intersection is not
+// prepared, if any item is not valid, if the filter is full or if it is
+// read-only (GolombMatch).
+func (client *Client) AddToIntersection(encryptedData [][]*big.Int) error {
+	return client.AddToIntersectionContext(context.Background(), encryptedData)
+}
+
+// AddToIntersectionContext function performs the same action as
+// AddToIntersection but checking the context provided between items, returning
+// the context error if it is done.
+func (client *Client) AddToIntersectionContext(ctx context.Context, encryptedData [][]*big.Int) error {
+	if client.filter == nil {
+		return errors.New("intersection not initialized")
+	} else if err := client.validateItems(ctx, encryptedData); err != nil {
+		return err
+	}
+	return client.addRecords(ctx, client.filter, encryptedData)
+}
+
+// RemoveFromIntersection function removes the current client re-encrypted
+// items provided (from another client) from the prepared intersection, without
+// preparing it again. Only the clients that use CountingMatch, CuckooMatch or
+// ExactMatch support it, and the items must have been added before, otherwise
+// other items can be removed from the filters. It returns an error if the
+// intersection is not prepared, if the match mode does not support it or if
+// any item is not valid.
+func (client *Client) RemoveFromIntersection(encryptedData [][]*big.Int) error {
+	return client.RemoveFromIntersectionContext(context.Background(), encryptedData)
+}
+
+// RemoveFromIntersectionContext function performs the same action as
+// RemoveFromIntersection but checking the context provided between items,
+// returning the context error if it is done.
+func (client *Client) RemoveFromIntersectionContext(ctx context.Context, encryptedData [][]*big.Int) error {
+	if client.filter == nil {
+		return errors.New("intersection not initialized")
+	} else if client.match == FilterMatch {
+		return errors.New("match mode does not support removals")
+	} else if err := client.validateItems(ctx, encryptedData); err != nil {
+		return err
+	}
+
+	var size int = client.cipher.Params().ElementSize
+	for _, item := range encryptedData {
+		if err := ctx.Err(); err != nil {
+			return err
+		} else if err = client.filter.Remove(record(item, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+	"github.com/lucasmenendez/gopsi/pkg/cuckoofilter"
+)
+
+func TestExactMatch(t *testing.T) {
+	if _, err := Init(WithMatchMode(MatchMode(-1))); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Without false positives, the intersection is exactly the common items,
+	// even between big sets.
+	var dataA, dataB []string
+	for i := 0; i < 200; i++ {
+		dataA = append(dataA, "a"+strings.Repeat("x", i%7)+string(rune('a'+i%26))+string(rune('0'+i/26)))
+		dataB = append(dataB, "b"+strings.Repeat("x", i%7)+string(rune('a'+i%26))+string(rune('0'+i/26)))
+	}
+	var expected = []string{"common 1", "common 2"}
+	dataA = append(dataA, expected...)
+	dataB = append(append([]string{}, expected...), dataB...)
+
+	clientA, clientB := agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(ExactMatch))
+	encrypted, _ := clientA.Encrypt(dataA)
+	reEncrypted, _ := clientB.EncryptExt(encrypted)
+	if err := clientA.PrepareIntersection(reEncrypted); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if _, ok := clientA.filter.(exactSet); !ok {
+		t.Fatalf("expected exactSet, got %T", clientA.filter)
+	} else if err = clientA.SetMatchMode(FilterMatch); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	encByB, _ := clientB.Encrypt(dataB)
+	common, _ := clientA.GetIntersection(encByB)
+	if result, err := clientB.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(expected, result) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+
+	// The stream API uses the same mode.
+	clientA, clientB = agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(ExactMatch))
+	encrypted, _ = clientA.Encrypt(dataA)
+	reEncrypted, _ = clientB.EncryptExt(encrypted)
+	var stream = &batchBuffer{batches: [][][]*big.Int{reEncrypted[:100], reEncrypted[100:]}}
+	if err := clientA.PrepareIntersectionStream(context.Background(), stream, len(reEncrypted)); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if set, ok := clientA.filter.(exactSet); !ok || len(set) != len(dataA) {
+		t.Fatalf("expected exactSet with %d items, got %T", len(dataA), clientA.filter)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	clientA, _ := agreedPair(t)
+
+	// Items with words of different lengths have different records.
+	var itemA = []*big.Int{big.NewInt(0x12), big.NewInt(0x345)}
+	var itemB = []*big.Int{big.NewInt(0x123), big.NewInt(0x45)}
+	var size int = clientA.cipher.Params().ElementSize
+	if recordA := record(itemA, size); len(recordA) != 2*size {
+		t.Fatalf("expected %d bytes, got %d", 2*size, len(recordA))
+	} else if recordB := record(itemB, size); reflect.DeepEqual(recordA, recordB) {
+		t.Fatal("expected different records, got the same")
+	}
+}
+
+func TestUpdateIntersection(t *testing.T) {
+	var dataA = []string{"hello world", "foo", "bar"}
+	var dataB = []string{"hello world", "foo", "bar", "baz"}
+	for _, mode := range []MatchMode{CountingMatch, CuckooMatch, ExactMatch, ScalableMatch, BlockedMatch, FilterMatch} {
+		clientA, clientB := agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(mode))
+		encrypted, _ := clientA.Encrypt(dataA)
+		reEncrypted, _ := clientB.EncryptExt(encrypted)
+		if err := clientA.AddToIntersection(reEncrypted); err == nil {
+			t.Fatal("expected error, got nil")
+		} else if err = clientA.PrepareIntersection(reEncrypted[:2]); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if _, ok := clientA.filter.(*cuckoofilter.CuckooFilter); ok != (mode == CuckooMatch) {
+			t.Fatalf("expected cuckoo filter only for CuckooMatch, got %T", clientA.filter)
+		}
+
+		// Add the last item after the preparation.
+		encByB, _ := clientB.Encrypt(dataB)
+		if err := clientA.AddToIntersection(reEncrypted[2:]); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if err = clientA.AddToIntersection([][]*big.Int{{big.NewInt(1)}}); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+
+		// Remove the first item, only supported by some modes.
+		err := clientA.RemoveFromIntersection(reEncrypted[:1])
+		if mode == FilterMatch || mode == ScalableMatch || mode == BlockedMatch {
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+
+		common, _ := clientA.GetIntersection(encByB)
+		if result, err := clientB.ParseIntersection(common); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if !reflect.DeepEqual([]string{"foo", "bar"}, result) {
+			t.Fatalf("expected [foo bar], got %v", result)
+		}
+	}
+}
+
+func TestScalableMatch(t *testing.T) {
+	var dataA, dataB []string
+	for i := 0; i < 300; i++ {
+		dataA = append(dataA, "a"+strings.Repeat("x", i%7)+string(rune('a'+i%26))+string(rune('0'+i/26)))
+	}
+	var expected = []string{"common 1", "common 2"}
+	dataA = append(dataA, expected...)
+	dataB = append(append(dataB, "other"), expected...)
+
+	// The number of items is required by the other modes.
+	clientA, clientB := agreedPair(t, WithEncoding(HashEncoding))
+	encrypted, _ := clientA.Encrypt(dataA)
+	reEncrypted, _ := clientB.EncryptExt(encrypted)
+	var stream = &batchBuffer{batches: [][][]*big.Int{reEncrypted}}
+	if err := clientA.PrepareIntersectionStream(context.Background(), stream, 0); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Without the number of items, the filter grows as the batches are read.
+	clientA, clientB = agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(ScalableMatch))
+	encrypted, _ = clientA.Encrypt(dataA)
+	reEncrypted, _ = clientB.EncryptExt(encrypted)
+	stream = &batchBuffer{batches: [][][]*big.Int{reEncrypted[:100], reEncrypted[100:]}}
+	if err := clientA.PrepareIntersectionStream(context.Background(), stream, 0); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if filter, ok := clientA.filter.(*bloomfilter.ScalableFilter); !ok {
+		t.Fatalf("expected scalable filter, got %T", clientA.filter)
+	} else if count := filter.Count(); count > uint(len(dataA)) || count < uint(len(dataA))-5 {
+		t.Fatalf("expected around %d items, got %d", len(dataA), count)
+	}
+
+	encByB, _ := clientB.Encrypt(dataB)
+	common, _ := clientA.GetIntersection(encByB)
+	if result, err := clientB.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(expected, result) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+
+	// Adding more items than the initial size grows the filter.
+	clientA, clientB = agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(ScalableMatch))
+	encrypted, _ = clientA.Encrypt(dataA)
+	reEncrypted, _ = clientB.EncryptExt(encrypted)
+	if err := clientA.PrepareIntersection(reEncrypted[:10]); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if err = clientA.AddToIntersection(reEncrypted[10:]); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if slices := clientA.filter.(*bloomfilter.ScalableFilter).Slices(); slices < 2 {
+		t.Fatalf("expected more than 1 filter, got %d", slices)
+	}
+}
+
+func TestGolombMatch(t *testing.T) {
+	var dataA = []string{"hello world", "foo", "bar", "qux"}
+	var dataB = []string{"bar", "baz", "hello world"}
+
+	clientA, clientB := agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(GolombMatch))
+	encrypted, _ := clientA.Encrypt(dataA)
+	reEncrypted, _ := clientB.EncryptExt(encrypted)
+	var stream = &batchBuffer{batches: [][][]*big.Int{reEncrypted[:2], reEncrypted[2:]}}
+	if err := clientA.PrepareIntersectionStream(context.Background(), stream, len(reEncrypted)); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if set, ok := clientA.filter.(*bloomfilter.GolombSet); !ok || set.Count() != uint(len(dataA)) {
+		t.Fatalf("expected Golomb-coded set with %d items, got %T", len(dataA), clientA.filter)
+	}
+
+	// The set is read-only.
+	if err := clientA.AddToIntersection(reEncrypted[:1]); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if err = clientA.RemoveFromIntersection(reEncrypted[:1]); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	encByB, _ := clientB.Encrypt(dataB)
+	common, _ := clientA.GetIntersection(encByB)
+	if result, err := clientB.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual([]string{"bar", "hello world"}, result) {
+		t.Fatalf("expected [bar hello world], got %v", result)
+	}
+
+	// The encoded set can be decoded by another party with the same result.
+	clientC, _ := agreedPair(t, WithEncoding(HashEncoding), WithMatchMode(GolombMatch))
+	if err := clientC.PrepareIntersection(reEncrypted); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	data, err := clientC.filter.MarshalBinary()
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	decoded, err := bloomfilter.UnmarshalGolombSet(data)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	var size int = clientC.cipher.Params().ElementSize
+	for _, item := range reEncrypted {
+		if !decoded.Test(record(item, size)) {
+			t.Fatal("expected item into the decoded set")
+		}
+	}
+}
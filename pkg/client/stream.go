@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// DefaultBatchSize contains the default number of items read, processed and
+// written at once by the streaming functions.
+const DefaultBatchSize = 4096
+
+// maxItemSize contains the maximum size in bytes of a single item read by
+// EncryptStream.
+const maxItemSize = 1 << 20
+
+// BatchReader interface describes a source of batches of encrypted items, such
+// as wire.Reader. ReadBatch must return io.EOF when there are no more batches.
+type BatchReader interface {
+	ReadBatch() ([][]*big.Int, error)
+}
+
+// BatchWriter interface describes a destination of batches of encrypted items,
+// such as wire.Writer.
+type BatchWriter interface {
+	WriteBatch([][]*big.Int) error
+}
+
+// EncryptStream function performs the same action as Encrypt but reading the
+// items from the io.Reader provided, one per line, and writing the encrypted
+// items into the BatchWriter provided in batches of batchSize items (or
+// DefaultBatchSize if it is not positive), keeping the memory used bounded by
+// the batch size. It returns the number of items encrypted. If the client uses
+// HashEncoding, it still keeps the original items in memory to parse the
+// intersection results.
+func (client *Client) EncryptStream(ctx context.Context, r io.Reader, w BatchWriter, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var count int
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, maxItemSize)
+	var batch []string = make([]string, 0, batchSize)
+	var flush = func() error {
+		encrypted, err := client.EncryptContext(ctx, batch)
+		if err != nil {
+			return err
+		} else if err = w.WriteBatch(encrypted); err != nil {
+			return err
+		}
+
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for scanner.Scan() {
+		if batch = append(batch, scanner.Text()); len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	} else if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// EncryptExtStream function performs the same action as EncryptExt but reading
+// the encrypted items of the other client from the BatchReader provided and
+// writing the re-encrypted items into the BatchWriter provided, one batch at a
+// time. It returns the number of items re-encrypted.
+func (client *Client) EncryptExtStream(ctx context.Context, r BatchReader, w BatchWriter) (int, error) {
+	var count int
+	err := readBatches(r, func(batch [][]*big.Int) error {
+		encrypted, err := client.EncryptExtContext(ctx, batch)
+		if err != nil {
+			return err
+		} else if err = w.WriteBatch(encrypted); err != nil {
+			return err
+		}
+
+		count += len(batch)
+		return nil
+	})
+	return count, err
+}
+
+// PrepareIntersectionStream function performs the same action as
+// PrepareIntersection but reading the re-encrypted items from the BatchReader
+// provided and adding them to the filter one batch at a time. The filter is
+// sized for the number of items provided, which must be known in advance,
+// except for the clients that use ScalableMatch, that accept a non-positive
+// number of items when it is unknown. The filter is not stored into the client
+// if it fails.
+func (client *Client) PrepareIntersectionStream(ctx context.Context, r BatchReader, size int) error {
+	if size <= 0 && client.match != ScalableMatch {
+		return errors.New("invalid number of items")
+	} else if client.cipher == nil {
+		return errors.New("common prime not defined")
+	} else if client.filter != nil {
+		return errors.New("bloom filter already defined, create a new instance")
+	}
+
+	var filter = client.newMembership(size)
+	err := readBatches(r, func(batch [][]*big.Int) error {
+		if err := client.validateItems(ctx, batch); err != nil {
+			return err
+		}
+		return client.addRecords(ctx, filter, batch)
+	})
+	if err != nil {
+		return err
+	}
+
+	client.filter = client.freezeMembership(filter)
+	return nil
+}
+
+// GetIntersectionStream function performs the same action as GetIntersection
+// but reading the encrypted items of the other client from the BatchReader
+// provided, testing them one batch at a time, and writing the common items of
+// each batch into the BatchWriter provided. It returns the number of common
+// items.
+func (client *Client) GetIntersectionStream(ctx context.Context, r BatchReader, w BatchWriter) (int, error) {
+	var count int
+	err := readBatches(r, func(batch [][]*big.Int) error {
+		common, err := client.GetIntersectionContext(ctx, batch)
+		if err != nil || len(common) == 0 {
+			return err
+		} else if err = w.WriteBatch(common); err != nil {
+			return err
+		}
+
+		count += len(common)
+		return nil
+	})
+	return count, err
+}
+
+// ParseIntersectionStream function performs the same action as
+// ParseIntersection but reading the intersection results from the BatchReader
+// provided and writing the decoded items into the io.Writer provided, one per
+// line. It returns the number of items parsed.
+func (client *Client) ParseIntersectionStream(ctx context.Context, r BatchReader, w io.Writer) (int, error) {
+	var count int
+	var buf = bufio.NewWriter(w)
+	err := readBatches(r, func(batch [][]*big.Int) error {
+		items, err := client.ParseIntersectionContext(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			buf.WriteString(item)
+			buf.WriteByte('\n')
+		}
+		count += len(items)
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, buf.Flush()
+}
+
+// readBatches function reads every batch from the BatchReader provided until
+// io.EOF, calling fn with each non-empty batch. It returns the first error
+// returned by fn or by the reader. If fn returns a *ValidationError, its index
+// is updated to be relative to the whole stream instead of the batch.
+func readBatches(r BatchReader, fn func([][]*big.Int) error) error {
+	var offset int
+	for {
+		batch, err := r.ReadBatch()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if len(batch) == 0 {
+			continue
+		} else if err = fn(batch); err != nil {
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				verr.Index += offset
+			}
+			return err
+		}
+		offset += len(batch)
+	}
+}
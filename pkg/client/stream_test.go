@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// batchBuffer struct implements both BatchReader and BatchWriter over an
+// in-memory slice of batches.
+type batchBuffer struct {
+	batches [][][]*big.Int
+}
+
+func (b *batchBuffer) WriteBatch(batch [][]*big.Int) error {
+	b.batches = append(b.batches, batch)
+	return nil
+}
+
+func (b *batchBuffer) ReadBatch() ([][]*big.Int, error) {
+	if len(b.batches) == 0 {
+		return nil, io.EOF
+	}
+	batch := b.batches[0]
+	b.batches = b.batches[1:]
+	return batch, nil
+}
+
+func TestStream(t *testing.T) {
+	var ctx = context.Background()
+	var dataA = "hello world\nfoo\nbar\nqux\n"
+	var dataB = "bar\nbaz\nhello world"
+
+	clientA, _ := Init()
+	clientB, _ := Init()
+	clientA.SetEncoding(HashEncoding)
+	clientB.SetEncoding(HashEncoding)
+	pubKey, _ := clientB.PubKey()
+	encPrime, _ := clientA.GenEncryptedPrime(pubKey)
+	clientB.SetEncryptedPrime(encPrime)
+	codec, _ := clientA.Codec()
+
+	// Encrypt both data streams in batches of two items.
+	var encA = &batchBuffer{}
+	if count, err := clientA.EncryptStream(ctx, strings.NewReader(dataA), encA, 2); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if count != 4 || len(encA.batches) != 2 {
+		t.Fatalf("expected 4 items in 2 batches, got %d in %d", count, len(encA.batches))
+	}
+	var encB bytes.Buffer
+	if count, err := clientB.EncryptStream(ctx, strings.NewReader(dataB), codec.NewWriter(&encB), 2); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if count != 3 {
+		t.Fatalf("expected 3 items, got %d", count)
+	}
+
+	// Re-encrypt client A data by client B and prepare the intersection.
+	var encAB = &batchBuffer{}
+	if count, err := clientB.EncryptExtStream(ctx, encA, encAB); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if count != 4 {
+		t.Fatalf("expected 4 items, got %d", count)
+	}
+	if err := clientA.PrepareIntersectionStream(ctx, encAB, 0); err == nil {
+		t.Fatal("expected error, got nil")
+	} else if err := clientA.PrepareIntersectionStream(ctx, encAB, 4); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// Get and parse the intersection.
+	var common = &batchBuffer{}
+	if _, err := clientA.GetIntersectionStream(ctx, codec.NewReader(&encB), common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	var output bytes.Buffer
+	if _, err := clientB.ParseIntersectionStream(ctx, common, &output); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// The bloom filter could include false positives, so check that the
+	// common items are included into the results.
+	var expected = []string{"bar", "hello world"}
+	for _, item := range expected {
+		if !strings.Contains(output.String(), item+"\n") {
+			t.Fatalf("expected %v, got %q", expected, output.String())
+		}
+	}
+}
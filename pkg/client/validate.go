@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrItemSize is returned, wrapped into a ValidationError, when an item has
+	// not the number of elements expected by the client encoding.
+	ErrItemSize = errors.New("hash encoded items must contain a single element")
+	// ErrDegenerateElement is returned, wrapped into a ValidationError, when an
+	// element is out of the range [2, p-2] of the SRA ciphers.
+	ErrDegenerateElement = errors.New("element out of the range [2, p-2]")
+	// ErrGroupMembership is returned, wrapped into a ValidationError, when an
+	// element does not belong to the subgroup of the configured group.
+	ErrGroupMembership = errors.New("element out of the group subgroup")
+)
+
+// ValidationError struct contains the position of the first invalid element of
+// a batch received from the other client: the index of the item into the batch
+// (Index), the index of the element into the item (Word, or -1 if the whole
+// item is invalid) and the reason (Err).
+type ValidationError struct {
+	Index int
+	Word  int
+	Err   error
+}
+
+// Error function returns the string representation of the ValidationError.
+func (err *ValidationError) Error() string {
+	if err.Word < 0 {
+		return fmt.Sprintf("invalid item %d: %v", err.Index, err.Err)
+	}
+	return fmt.Sprintf("invalid item %d (element %d): %v", err.Index, err.Word, err.Err)
+}
+
+// Unwrap function returns the reason of the ValidationError.
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// ValidateItems function checks every item of the batch provided (from another
+// client) as the other steps of the intersection do before processing it,
+// without changing the client state. It allows to check a batch before
+// preparing an intersection that depends on it. It returns an error if the
+// common prime is not defined or a *ValidationError with the first invalid
+// item.
+func (client *Client) ValidateItems(input [][]*big.Int) error {
+	return client.ValidateItemsContext(context.Background(), input)
+}
+
+// ValidateItemsContext function performs the same action as ValidateItems but
+// checking the context provided between items, returning the context error if
+// it is done.
+func (client *Client) ValidateItemsContext(ctx context.Context, input [][]*big.Int) error {
+	if client.cipher == nil {
+		return errors.New("common prime not defined")
+	}
+	return client.validateItems(ctx, input)
+}
+
+// validateItems function checks every item of the batch provided before
+// processing it, splitting the work between the client workers: each item must
+// have the number of elements expected by the client encoding, and each
+// element must be valid for the client cipher (read more in
+// CommutativeCipher.ValidateElement), which rejects degenerate values and
+// elements out of the configured group. It returns a *ValidationError with the
+// first invalid item of the batch, or the context error if it is done.
+func (client *Client) validateItems(ctx context.Context, input [][]*big.Int) error {
+	var errs []error = make([]error, len(input))
+	err := client.parallel(ctx, len(input), func(i int) error {
+		errs[i] = client.validateItem(input[i])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			err.(*ValidationError).Index = i
+			return err
+		}
+	}
+	return nil
+}
+
+// validateItem function checks the item provided, returning a
+// *ValidationError without index if it is not valid.
+func (client *Client) validateItem(item []*big.Int) error {
+	if client.encoding == HashEncoding && len(item) != 1 {
+		return &ValidationError{Word: -1, Err: ErrItemSize}
+	}
+
+	for w, word := range item {
+		if err := client.cipher.ValidateElement(word); err != nil {
+			return &ValidationError{Word: w, Err: err}
+		}
+	}
+	return nil
+}
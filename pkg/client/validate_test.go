@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/sra"
+)
+
+// agreedPair function returns two clients initialized with the options
+// provided that share the same common prime.
+func agreedPair(t *testing.T, options ...Option) (*Client, *Client) {
+	pubA, keyA, _ := ed25519.GenerateKey(rand.Reader)
+	pubB, keyB, _ := ed25519.GenerateKey(rand.Reader)
+	options = append(options, WithRSABits(0))
+	clientA, _ := Init(append(options, WithIdentity(keyA), WithPeerIdentity(pubB))...)
+	clientB, _ := Init(append(options, WithIdentity(keyB), WithPeerIdentity(pubA))...)
+	proposal, _ := clientA.ProposeParams()
+	response, _ := clientB.RespondParams(proposal)
+	confirmation, err := clientA.AcceptParams(response)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if err = clientB.ConfirmParams(confirmation); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	return clientA, clientB
+}
+
+func TestValidateItems(t *testing.T) {
+	clientA, clientB := agreedPair(t, WithEncoding(HashEncoding))
+	valid, _ := clientA.Encrypt([]string{"hello world", "foo"})
+
+	// Look for an element out of the subgroup of quadratic residues.
+	var nonResidue = big.NewInt(2)
+	for big.Jacobi(nonResidue, sra.FFDHE2048.P) != -1 {
+		nonResidue.Add(nonResidue, big.NewInt(1))
+	}
+
+	var p = clientA.CommonPrime
+	var cases = []struct {
+		element  *big.Int
+		expected error
+	}{
+		{big.NewInt(0), ErrDegenerateElement},
+		{big.NewInt(1), ErrDegenerateElement},
+		{big.NewInt(-5), ErrDegenerateElement},
+		{new(big.Int).Sub(p, big.NewInt(1)), ErrDegenerateElement},
+		{p, ErrDegenerateElement},
+		{new(big.Int).Add(p, big.NewInt(4)), ErrDegenerateElement},
+		{nonResidue, ErrGroupMembership},
+	}
+	for _, c := range cases {
+		var input = [][]*big.Int{valid[0], valid[1], {c.element}}
+
+		var verr *ValidationError
+		if _, err := clientB.EncryptExt(input); !errors.As(err, &verr) || verr.Index != 2 || verr.Word != 0 {
+			t.Fatalf("expected validation error of item 2, got %v", err)
+		} else if !errors.Is(err, c.expected) {
+			t.Fatalf("expected %v, got %v", c.expected, err)
+		} else if err = clientB.ValidateItems(input); !errors.As(err, &verr) || verr.Index != 2 {
+			t.Fatalf("expected validation error of item 2, got %v", err)
+		} else if _, err = clientB.GetIntersection(input); err == nil {
+			t.Fatal("expected error, got nil")
+		} else if err = clientB.PrepareIntersection(input); err == nil {
+			t.Fatal("expected error, got nil")
+		} else if _, err = clientA.ParseIntersection(input); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+
+	if err := clientB.ValidateItems(valid); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if uninitialized, _ := Init(WithRSABits(0)); uninitialized.ValidateItems(valid) == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var verr *ValidationError
+	if _, err := clientB.EncryptExt([][]*big.Int{valid[0], {}}); !errors.As(err, &verr) || verr.Index != 1 || verr.Word != -1 {
+		t.Fatalf("expected validation error of item 1, got %v", err)
+	} else if !errors.Is(err, ErrItemSize) {
+		t.Fatalf("expected ErrItemSize, got %v", err)
+	} else if clientB.filter != nil {
+		t.Fatal("expected nil filter, got filter")
+	}
+
+	// The index of the invalid items is relative to the whole stream.
+	var stream = &batchBuffer{batches: [][][]*big.Int{valid, {valid[0], {big.NewInt(1)}}}}
+	if _, err := clientB.EncryptExtStream(context.Background(), stream, &batchBuffer{}); !errors.As(err, &verr) || verr.Index != 3 {
+		t.Fatalf("expected validation error of item 3, got %v", err)
+	}
+}
+
+func TestValidateCurveItems(t *testing.T) {
+	clientA, clientB := agreedPair(t, WithCurve(elliptic.P256()))
+	valid, _ := clientA.Encrypt([]string{"hello world"})
+
+	var invalid = []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Add(valid[0][0], new(big.Int).Lsh(big.NewInt(2), 256)),
+		new(big.Int).Lsh(valid[0][0], 8),
+	}
+	for _, element := range invalid {
+		var verr *ValidationError
+		if _, err := clientB.EncryptExt([][]*big.Int{valid[0], {element}}); !errors.As(err, &verr) || verr.Index != 1 {
+			t.Fatalf("expected validation error of item 1, got %v", err)
+		}
+	}
+}
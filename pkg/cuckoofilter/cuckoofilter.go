@@ -0,0 +1,327 @@
+// Package cuckoofilter implements a cuckoo filter, an approximate membership
+// structure that supports removing items and, for low false positive rates,
+// requires less space than a Bloom filter. Every item is stored as a short
+// fingerprint into one of its two candidate buckets, relocating the
+// fingerprints of a full bucket to their alternative bucket when required.
+// Read more about cuckoo filters here:
+// https://www.cs.cmu.edu/~dga/papers/cuckoo-conext2014.pdf.
+package cuckoofilter
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/lucasmenendez/gopsi/internal/hashmix"
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+)
+
+// Parameters of the filter: the number of fingerprints of each bucket, the
+// maximum ratio of occupied slots that the filter is sized for, the maximum
+// number of relocations of each insertion, and the range of sizes in bits of
+// the fingerprints.
+const (
+	bucketSize         = 4
+	loadFactor         = 0.95
+	maxKicks           = 500
+	minFingerprintBits = 4
+	maxFingerprintBits = 32
+)
+
+// wordSize contains the number of bits of each word of the slots.
+const wordSize = 64
+
+// ErrFull is returned when an item cannot be added because the filter is full.
+var ErrFull = errors.New("cuckoofilter: filter is full")
+
+// CuckooFilter struct contains the required parameters to create and use a
+// cuckoo filter: the packed fingerprints of every slot of every bucket
+// (slots), the number of buckets (buckets), the size in bits of the
+// fingerprints (bits), the size of the filter (n), the number of items stored
+// (count), the fingerprint that could not be relocated when the filter got
+// full (victim) and the hash function (hasher). The fingerprint 0 represents
+// an empty slot. It is safe for concurrent use.
+type CuckooFilter struct {
+	mu      sync.RWMutex
+	slots   []uint64
+	buckets uint
+	bits    uint
+	n       uint
+	count   uint
+	victim  victim
+	hasher  bloomfilter.Hasher
+	seed    uint64
+}
+
+// victim struct contains a fingerprint that is not stored into any bucket
+// (fp) and one of its candidate buckets (index).
+type victim struct {
+	index uint
+	fp    uint32
+}
+
+// Option type defines a function that sets an optional parameter of a
+// CuckooFilter during its creation, such as WithHasher.
+type Option func(*CuckooFilter)
+
+// WithHasher function returns an Option that sets the hash function of the
+// filter, bloomfilter.FNVHasher by default (read more in bloomfilter.Hasher).
+func WithHasher(hasher bloomfilter.Hasher) Option {
+	return func(f *CuckooFilter) {
+		if hasher != nil {
+			f.hasher = hasher
+		}
+	}
+}
+
+// NewFilter function initializes a new CuckooFilter with the size and false
+// positive rate provided. The fingerprints are sized to get the false positive
+// rate provided, fp = 2 * 4 / 2^bits, between 4 and 32 bits, and the number of
+// buckets is the minimum to store the number of items provided with a load
+// factor of 95%. A size lower than 1 is considered as 1.
+func NewFilter(size int, fp float64, options ...Option) *CuckooFilter {
+	if size < 1 {
+		size = 1
+	}
+
+	var filter = &CuckooFilter{n: uint(size), hasher: bloomfilter.FNVHasher()}
+	for _, option := range options {
+		option(filter)
+	}
+
+	// Calculate the size of the fingerprints by the false positive rate
+	// according to the following formula: bits = log2(2 * b / fp).
+	var bits float64 = math.Ceil(math.Log2(2 * bucketSize / fp))
+	if filter.bits = maxFingerprintBits; bits < maxFingerprintBits {
+		filter.bits = minFingerprintBits
+		if bits > minFingerprintBits {
+			filter.bits = uint(bits)
+		}
+	}
+
+	// Calculate the number of buckets by the size of the filter and the load
+	// factor, and initialize the slots packing the fingerprints into words.
+	filter.buckets = uint(math.Ceil(float64(size) / (bucketSize * loadFactor)))
+	filter.slots = make([]uint64, numberOfWords(filter.buckets, filter.bits))
+	return filter
+}
+
+// numberOfWords function returns the number of words required to store the
+// fingerprints of the number of buckets and the size provided.
+func numberOfWords(buckets, bits uint) uint {
+	return (buckets*bucketSize*bits + wordSize - 1) / wordSize
+}
+
+// N function returns the number of items that the filter was sized for.
+func (f *CuckooFilter) N() uint {
+	return f.n
+}
+
+// Count function returns the number of items stored into the filter.
+func (f *CuckooFilter) Count() uint {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.count
+}
+
+// FingerprintBits function returns the size in bits of the fingerprints.
+func (f *CuckooFilter) FingerprintBits() uint {
+	return f.bits
+}
+
+// LoadFactor function returns the ratio of slots of the filter that are
+// occupied.
+func (f *CuckooFilter) LoadFactor() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return float64(f.count) / float64(f.buckets*bucketSize)
+}
+
+// Add function inserts one (or more) items into the filter. If both candidate
+// buckets of an item are full, it relocates the fingerprints of one of them to
+// their alternative buckets, up to 500 times. If it is still not stored, the
+// last relocated fingerprint is kept apart, so no item is lost, but the filter
+// is full and the next items return ErrFull.
+func (f *CuckooFilter) Add(items ...[]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, item := range items {
+		if f.victim.fp != 0 {
+			return ErrFull
+		}
+
+		var index, fp = f.locate(item)
+		if f.insert(index, fp) || f.insert(f.altIndex(index, fp), fp) {
+			f.count++
+			continue
+		}
+
+		// Relocate a random fingerprint of one of the candidate buckets to its
+		// alternative bucket, until some of them has a free slot.
+		if f.random()%2 == 1 {
+			index = f.altIndex(index, fp)
+		}
+		for kick := 0; kick < maxKicks && fp != 0; kick++ {
+			var slot uint = index*bucketSize + uint(f.random()%bucketSize)
+			var relocated uint32 = f.slot(slot)
+			f.setSlot(slot, fp)
+
+			fp, index = relocated, f.altIndex(index, relocated)
+			if f.insert(index, fp) {
+				fp = 0
+			}
+		}
+
+		f.count++
+		if fp != 0 {
+			f.victim = victim{index: index, fp: fp}
+		}
+	}
+	return nil
+}
+
+// Test function checks if the filter contains the item provided, which is
+// true if any of its candidate buckets contains its fingerprint.
+func (f *CuckooFilter) Test(item []byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var index, fp = f.locate(item)
+	var alt uint = f.altIndex(index, fp)
+	if f.victim.fp == fp && (f.victim.index == index || f.victim.index == alt) {
+		return true
+	}
+	return f.find(index, fp) >= 0 || f.find(alt, fp) >= 0
+}
+
+// TestMultiple function tests multiple items at the same time, using the
+// CuckooFilter.Test function.
+func (f *CuckooFilter) TestMultiple(items ...[]byte) (results []bool) {
+	results = make([]bool, len(items))
+	for i, item := range items {
+		results[i] = f.Test(item)
+	}
+	return
+}
+
+// Remove function deletes one (or more) items from the filter, deleting one
+// copy of the fingerprint of each item from its candidate buckets. The items
+// that are not into the filter are skipped. The items must have been added
+// before, otherwise other items with the same fingerprint and buckets can be
+// removed. It never fails, it returns an error to implement
+// membership.Filter.
+func (f *CuckooFilter) Remove(items ...[]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, item := range items {
+		var index, fp = f.locate(item)
+		var alt uint = f.altIndex(index, fp)
+		if f.victim.fp == fp && (f.victim.index == index || f.victim.index == alt) {
+			f.victim = victim{}
+			f.count--
+			continue
+		}
+
+		var slot int = f.find(index, fp)
+		if slot < 0 {
+			if slot = f.find(alt, fp); slot < 0 {
+				continue
+			}
+		}
+		f.setSlot(uint(slot), 0)
+		f.count--
+
+		// Try to store the victim into the free slot.
+		if f.victim.fp != 0 {
+			if f.insert(f.victim.index, f.victim.fp) || f.insert(f.altIndex(f.victim.index, f.victim.fp), f.victim.fp) {
+				f.victim = victim{}
+			}
+		}
+	}
+	return nil
+}
+
+// locate function returns the first candidate bucket of the item provided and
+// its fingerprint, calculated with independent bits of its hash. The hash is
+// mixed first (read more in hashmix.Mix64), because the bits of weak hash
+// functions such as FNV-1a are not independent enough to fill the buckets
+// evenly. The fingerprint is never 0.
+func (f *CuckooFilter) locate(item []byte) (uint, uint32) {
+	var hashed uint64 = hashmix.Mix64(f.hasher.Sum64(item))
+	var fp uint32 = uint32(hashed & (1<<f.bits - 1))
+	if fp == 0 {
+		fp = 1
+	}
+	return uint(hashed>>32) % f.buckets, fp
+}
+
+// altIndex function returns the alternative candidate bucket of the bucket and
+// fingerprint provided, calculated as (hash(fp) - index) mod buckets, so it
+// does not depend on the item and altIndex(altIndex(i, fp), fp) = i for any
+// number of buckets.
+func (f *CuckooFilter) altIndex(index uint, fp uint32) uint {
+	var hashed uint = uint((uint64(fp)*0x9e3779b97f4a7c15)>>32) % f.buckets
+	return (hashed + f.buckets - index) % f.buckets
+}
+
+// insert function stores the fingerprint provided into the first empty slot
+// of the bucket provided, returning false if it is full.
+func (f *CuckooFilter) insert(index uint, fp uint32) bool {
+	for slot := index * bucketSize; slot < (index+1)*bucketSize; slot++ {
+		if f.slot(slot) == 0 {
+			f.setSlot(slot, fp)
+			return true
+		}
+	}
+	return false
+}
+
+// find function returns the slot of the bucket provided that contains the
+// fingerprint provided, or -1 if it does not contain it.
+func (f *CuckooFilter) find(index uint, fp uint32) int {
+	for slot := index * bucketSize; slot < (index+1)*bucketSize; slot++ {
+		if f.slot(slot) == fp {
+			return int(slot)
+		}
+	}
+	return -1
+}
+
+// slot function returns the fingerprint of the slot provided, that can be
+// splitted between two words.
+func (f *CuckooFilter) slot(slot uint) uint32 {
+	var bit uint = slot * f.bits
+	var word, offset uint = bit / wordSize, bit % wordSize
+	var value uint64 = f.slots[word] >> offset
+	if offset+f.bits > wordSize {
+		value |= f.slots[word+1] << (wordSize - offset)
+	}
+	return uint32(value & (1<<f.bits - 1))
+}
+
+// setSlot function stores the fingerprint provided into the slot provided,
+// that can be splitted between two words.
+func (f *CuckooFilter) setSlot(slot uint, fp uint32) {
+	var bit uint = slot * f.bits
+	var word, offset uint = bit / wordSize, bit % wordSize
+	var mask uint64 = 1<<f.bits - 1
+	f.slots[word] = f.slots[word]&^(mask<<offset) | uint64(fp)<<offset
+	if offset+f.bits > wordSize {
+		var shift uint = wordSize - offset
+		f.slots[word+1] = f.slots[word+1]&^(mask>>shift) | uint64(fp)>>shift
+	}
+}
+
+// random function returns the next number of a xorshift pseudorandom sequence,
+// used to choose the fingerprints to relocate.
+func (f *CuckooFilter) random() uint64 {
+	if f.seed == 0 {
+		f.seed = 0x2545f4914f6cdd1d
+	}
+	f.seed ^= f.seed << 13
+	f.seed ^= f.seed >> 7
+	f.seed ^= f.seed << 17
+	return f.seed
+}
@@ -0,0 +1,168 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+	"github.com/lucasmenendez/gopsi/pkg/membership"
+)
+
+var _ membership.Filter = (*CuckooFilter)(nil)
+
+func TestSlots(t *testing.T) {
+	// 17-bit fingerprints are splitted between words.
+	filter := NewFilter(100, 0.0001)
+	if filter.FingerprintBits() != 17 {
+		t.Fatalf("Expected 17 bits, got %d", filter.FingerprintBits())
+	}
+
+	var total uint = filter.buckets * bucketSize
+	for slot := uint(0); slot < total; slot++ {
+		filter.setSlot(slot, uint32(slot*7919)&(1<<17-1)|1)
+	}
+	for slot := uint(0); slot < total; slot++ {
+		if expected := uint32(slot*7919)&(1<<17-1) | 1; filter.slot(slot) != expected {
+			t.Fatalf("Expected %x at slot %d, got %x", expected, slot, filter.slot(slot))
+		}
+	}
+}
+
+func TestAltIndex(t *testing.T) {
+	filter := NewFilter(1000, 0.01)
+	for index := uint(0); index < filter.buckets; index++ {
+		for _, fp := range []uint32{1, 2, 127, 255} {
+			if alt := filter.altIndex(index, fp); alt >= filter.buckets || filter.altIndex(alt, fp) != index {
+				t.Fatalf("Expected alternative index of %d to be reversible, got %d", index, alt)
+			}
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var n, fp = 10000, 0.001
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	filter := NewFilter(n, fp)
+	if err := filter.Add(items...); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	} else if filter.Count() != uint(n) {
+		t.Fatalf("Expected %d items, got %d", n, filter.Count())
+	}
+	for i, result := range filter.TestMultiple(items...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", items[i])
+		}
+	}
+
+	// Check the actual false positive rate with items not added.
+	var positives int
+	for i := 0; i < n; i++ {
+		if filter.Test([]byte(fmt.Sprintf("other-%d", i))) {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(n); actual > fp*2 {
+		t.Errorf("Expected false positive rate close to %f, got %f", fp, actual)
+	}
+
+	// Remove the first half of the items, the other half must remain.
+	filter.Remove(items[:n/2]...)
+	if filter.Count() != uint(n/2) {
+		t.Fatalf("Expected %d items, got %d", n/2, filter.Count())
+	}
+	for i, result := range filter.TestMultiple(items[n/2:]...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", items[n/2+i])
+		}
+	}
+	positives = 0
+	for _, result := range filter.TestMultiple(items[:n/2]...) {
+		if result {
+			positives++
+		}
+	}
+	if actual := float64(positives) / float64(n/2); actual > fp*2 {
+		t.Errorf("Expected removed items, got %d positives", positives)
+	}
+}
+
+func TestFullFilter(t *testing.T) {
+	filter := NewFilter(10, 0.01, WithHasher(bloomfilter.SHA256Hasher()))
+
+	// Add items until the filter is full, every added item must remain.
+	var added [][]byte
+	var err error
+	for i := 0; err == nil; i++ {
+		item := []byte(fmt.Sprintf("item-%d", i))
+		if err = filter.Add(item); err == nil {
+			added = append(added, item)
+		}
+	}
+	if err != ErrFull {
+		t.Fatalf("Expected %v, got %v", ErrFull, err)
+	} else if len(added) < 10 {
+		t.Fatalf("Expected at least 10 items, got %d", len(added))
+	}
+	for i, result := range filter.TestMultiple(added...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", added[i])
+		}
+	}
+
+	// Removing any item frees space for new items.
+	filter.Remove(added[0])
+	if err := filter.Add(added[0]); err != nil {
+		t.Fatalf("Expected nil, got %s", err)
+	}
+	for i, result := range filter.TestMultiple(added...) {
+		if !result {
+			t.Fatalf("Expected that filter contains '%s'.", added[i])
+		}
+	}
+}
+
+func TestConcurrentFilter(t *testing.T) {
+	var n, workers = 500, 8
+	filter := NewFilter(n*workers, 0.001)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				item := []byte(fmt.Sprintf("item-%d-%d", w, i))
+				if err := filter.Add(item); err != nil {
+					t.Errorf("Expected nil, got %s", err)
+				} else if !filter.Test(item) {
+					t.Errorf("Expected that filter contains '%s'.", item)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if filter.Count() != uint(n*workers) {
+		t.Errorf("Expected %d items, got %d", n*workers, filter.Count())
+	}
+}
+
+func BenchmarkFilterSize(b *testing.B) {
+	for _, fp := range []float64{0.01, 0.0001} {
+		var n = 100000
+		b.Run(fmt.Sprintf("fp=%g", fp), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewFilter(n, fp)
+			}
+			cuckoo := NewFilter(n, fp)
+			bloom := bloomfilter.NewFilter(n, fp)
+			b.ReportMetric(float64(len(cuckoo.slots)*64)/float64(n), "cuckoo-bits/item")
+			b.ReportMetric(float64(bloom.M())/float64(n), "bloom-bits/item")
+		})
+	}
+}
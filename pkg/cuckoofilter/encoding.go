@@ -0,0 +1,145 @@
+package cuckoofilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+)
+
+// Version contains the current version of the binary format of the filters.
+const Version byte = 1
+
+// Sizes of the binary format: the header contains the magic string, the
+// version, the hash algorithm, the size of the fingerprints, the number of
+// buckets, the size of the filter, the number of items and the victim, and the
+// checksum is the CRC-32 (IEEE) of the header and the slots.
+const (
+	headerSize   = 4 + 1 + 1 + 1 + 3*8 + 8 + 4
+	checksumSize = 4
+)
+
+var magic = []byte("GPCK")
+
+var (
+	// ErrFormat is returned when the input is not a valid encoded filter.
+	ErrFormat = errors.New("cuckoofilter: invalid format")
+	// ErrVersion is returned when the input version is not supported.
+	ErrVersion = errors.New("cuckoofilter: unsupported version")
+	// ErrChecksum is returned when the checksum of the input does not match.
+	ErrChecksum = errors.New("cuckoofilter: checksum mismatch")
+	// ErrHasher is returned when the hash algorithm of the input does not
+	// match the filter Hasher.
+	ErrHasher = errors.New("cuckoofilter: hash algorithm mismatch")
+)
+
+// MarshalBinary function encodes the filter into a versioned binary format,
+// implementing encoding.BinaryMarshaler. It starts with a header that contains
+// the magic string "GPCK", the format version, the hash algorithm, the size of
+// the fingerprints, and the number of buckets, the size of the filter, the
+// number of items and the victim bucket as big-endian 64-bit integers followed
+// by the victim fingerprint as a big-endian 32-bit integer. Then, it contains
+// the packed slots as big-endian 64-bit words and the CRC-32 checksum of
+// everything before it. The key of a keyed Hasher is not encoded, so it must
+// be shared apart.
+func (f *CuckooFilter) MarshalBinary() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var data []byte = make([]byte, headerSize+len(f.slots)*8+checksumSize)
+	copy(data, magic)
+	data[4], data[5], data[6] = Version, byte(f.hasher.Algorithm()), byte(f.bits)
+	binary.BigEndian.PutUint64(data[7:], uint64(f.buckets))
+	binary.BigEndian.PutUint64(data[15:], uint64(f.n))
+	binary.BigEndian.PutUint64(data[23:], uint64(f.count))
+	binary.BigEndian.PutUint64(data[31:], uint64(f.victim.index))
+	binary.BigEndian.PutUint32(data[39:], f.victim.fp)
+
+	for i, word := range f.slots {
+		binary.BigEndian.PutUint64(data[headerSize+i*8:], word)
+	}
+	var body []byte = data[:len(data)-checksumSize]
+	binary.BigEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+	return data, nil
+}
+
+// UnmarshalBinary function decodes a filter encoded by MarshalBinary into the
+// current one, implementing encoding.BinaryUnmarshaler. It returns an error if
+// the input is not valid or its checksum does not match. As
+// bloomfilter.BloomFilter.UnmarshalBinary does, the current filter Hasher is
+// kept if it uses the hash algorithm of the input, otherwise the unkeyed
+// algorithms are instanced by default and the keyed ones return ErrHasher, so
+// the filters that use a keyed Hasher must be decoded with UnmarshalFilter and
+// WithHasher.
+func (f *CuckooFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize+checksumSize {
+		return fmt.Errorf("%w: too short", ErrFormat)
+	} else if string(data[:4]) != string(magic) {
+		return fmt.Errorf("%w: bad magic", ErrFormat)
+	} else if data[4] != Version {
+		return fmt.Errorf("%w: %d", ErrVersion, data[4])
+	}
+
+	var body []byte = data[:len(data)-checksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return ErrChecksum
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var hasher bloomfilter.Hasher = f.hasher
+	var algorithm bloomfilter.HashAlgorithm = bloomfilter.HashAlgorithm(data[5])
+	if hasher == nil || hasher.Algorithm() != algorithm {
+		switch algorithm {
+		case bloomfilter.FNV1a:
+			hasher = bloomfilter.FNVHasher()
+		case bloomfilter.SHA256:
+			hasher = bloomfilter.SHA256Hasher()
+		default:
+			return fmt.Errorf("%w: %s", ErrHasher, algorithm)
+		}
+	}
+
+	// Check the parameters against the size of the slots, that must be the
+	// words required to store every bucket without any bit set beyond them.
+	var bits uint64 = uint64(data[6])
+	var buckets, n, count = binary.BigEndian.Uint64(data[7:]), binary.BigEndian.Uint64(data[15:]), binary.BigEndian.Uint64(data[23:])
+	var victimIndex, victimFP = binary.BigEndian.Uint64(data[31:]), binary.BigEndian.Uint32(data[39:])
+	var encoded []byte = body[headerSize:]
+	if bits < minFingerprintBits || bits > maxFingerprintBits || buckets == 0 || buckets > uint64(len(encoded)) || n == 0 || len(encoded)%8 != 0 {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	} else if uint64(len(encoded)/8) != (buckets*bucketSize*bits+wordSize-1)/wordSize {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	} else if count > buckets*bucketSize+1 || victimIndex >= buckets || uint64(victimFP)>>bits != 0 {
+		return fmt.Errorf("%w: invalid parameters", ErrFormat)
+	}
+
+	var slots []uint64 = make([]uint64, len(encoded)/8)
+	for i := range slots {
+		slots[i] = binary.BigEndian.Uint64(encoded[i*8:])
+	}
+	if rest := buckets * bucketSize * bits % wordSize; rest != 0 && slots[len(slots)-1]>>rest != 0 {
+		return fmt.Errorf("%w: bits out of range", ErrFormat)
+	}
+
+	f.slots, f.bits, f.buckets, f.n, f.count = slots, uint(bits), uint(buckets), uint(n), uint(count)
+	f.victim, f.hasher = victim{index: uint(victimIndex), fp: victimFP}, hasher
+	return nil
+}
+
+// UnmarshalFilter function decodes a filter encoded by MarshalBinary, applying
+// the options provided before decoding it, for example, to provide the keyed
+// Hasher used to encode it.
+func UnmarshalFilter(data []byte, options ...Option) (*CuckooFilter, error) {
+	var filter = &CuckooFilter{}
+	for _, option := range options {
+		option(filter)
+	}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
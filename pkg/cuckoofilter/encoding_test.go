@@ -0,0 +1,92 @@
+package cuckoofilter
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/bloomfilter"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*CuckooFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*CuckooFilter)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	items := make([][]byte, 100)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	for _, hasher := range []bloomfilter.Hasher{bloomfilter.FNVHasher(), bloomfilter.DeriveSipHasher([]byte("secret"))} {
+		filter := NewFilter(len(items), 0.0001, WithHasher(hasher))
+		filter.Add(items...)
+		data, err := filter.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Expected nil, got %s", err)
+		}
+
+		decoded, err := UnmarshalFilter(data, WithHasher(hasher))
+		if err != nil {
+			t.Fatalf("Expected nil, got %s", err)
+		} else if decoded.N() != filter.N() || decoded.Count() != filter.Count() || decoded.FingerprintBits() != filter.FingerprintBits() {
+			t.Fatalf("Expected n, count, bits = %d, %d, %d, got %d, %d, %d", filter.N(), filter.Count(), filter.FingerprintBits(), decoded.N(), decoded.Count(), decoded.FingerprintBits())
+		}
+		for i, result := range decoded.TestMultiple(items...) {
+			if !result {
+				t.Errorf("Expected that decoded filter contains '%s'.", items[i])
+			}
+		}
+
+		// The decoded filter supports every operation.
+		decoded.Remove(items[0])
+		if decoded.Test(items[0]) || decoded.Count() != filter.Count()-1 {
+			t.Errorf("Expected that decoded filter not contains '%s'.", items[0])
+		}
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	filter := NewFilter(10, 0.01)
+	filter.Add([]byte("aaa"))
+	data, _ := filter.MarshalBinary()
+
+	// modified function returns a copy of the encoded filter after applying
+	// the function provided and updating its checksum.
+	modified := func(fn func([]byte)) []byte {
+		result := append([]byte{}, data...)
+		fn(result)
+		body := result[:len(result)-checksumSize]
+		binary.BigEndian.PutUint32(result[len(body):], crc32.ChecksumIEEE(body))
+		return result
+	}
+
+	keyed := NewFilter(10, 0.01, WithHasher(bloomfilter.DeriveSipHasher([]byte("secret"))))
+	keyedData, _ := keyed.MarshalBinary()
+	bloomData, _ := bloomfilter.NewFilter(10, 0.01).MarshalBinary()
+
+	var cases = []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{"empty", nil, ErrFormat},
+		{"bloom filter", bloomData, ErrFormat},
+		{"checksum", append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^1), ErrChecksum},
+		{"version", modified(func(d []byte) { d[4] = Version + 1 }), ErrVersion},
+		{"keyed", keyedData, ErrHasher},
+		{"fingerprint bits", modified(func(d []byte) { d[6] = maxFingerprintBits + 1 }), ErrFormat},
+		{"buckets", modified(func(d []byte) { binary.BigEndian.PutUint64(d[7:], 1<<62) }), ErrFormat},
+		{"victim", modified(func(d []byte) { binary.BigEndian.PutUint64(d[31:], 1000) }), ErrFormat},
+		{"bits out of range", modified(func(d []byte) { d[len(d)-checksumSize-8] = 0xff }), ErrFormat},
+	}
+	for _, c := range cases {
+		if _, err := UnmarshalFilter(c.input); !errors.Is(err, c.expected) {
+			t.Errorf("Expected %v for %s input, got %v", c.expected, c.name, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package ec implements a commutative encryption scheme over the P-256
+// elliptic curve, as an alternative to the SRA scheme of the sra package.
+// Items are hashed to points of the curve (read more in HashToCurve) and
+// encrypted by scalar multiplication: E(P) = k * P. Since the scalar
+// multiplication is commutative, k1 * (k2 * P) = k2 * (k1 * P), so it can be
+// used to perform private set intersections with much smaller elements than
+// modular exponentiation.
+//
+// Points are represented as *big.Int to be compatible with the rest of the
+// project, encoding the compressed SEC 1 representation of the point (33
+// bytes) as a big-endian integer.
+package ec
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// CurveName contains the name of the curve used by the package.
+const CurveName = "P-256"
+
+// ElementSize contains the size in bytes of an encoded point.
+const ElementSize = 33
+
+// Key struct contains the curve, the secret scalar (k) used to encrypt and its
+// inverse modulo the curve order (k^-1), used to decrypt.
+type Key struct {
+	curve     elliptic.Curve
+	secret    *big.Int
+	secretInv *big.Int
+}
+
+// NewKey function generates a new Key for the P-256 curve with a random secret
+// scalar in the range [1, N-1], where N is the order of the curve.
+func NewKey() (key *Key, err error) {
+	key = &Key{curve: elliptic.P256()}
+
+	var n *big.Int = key.curve.Params().N
+	var max = new(big.Int).Sub(n, big.NewInt(1))
+	if key.secret, err = rand.Int(rand.Reader, max); err != nil {
+		return nil, err
+	}
+	key.secret.Add(key.secret, big.NewInt(1))
+
+	key.secretInv = new(big.Int).ModInverse(key.secret, n)
+	return
+}
+
+// Encrypt function encrypts the encoded point provided multiplying it by the
+// secret scalar (k * P). It returns nil if the input is not a valid encoded
+// point.
+func (key *Key) Encrypt(element *big.Int) *big.Int {
+	return key.multiply(element, key.secret)
+}
+
+// Decrypt function decrypts the encoded point provided multiplying it by the
+// inverse of the secret scalar (k^-1 * P). It returns nil if the input is not
+// a valid encoded point.
+func (key *Key) Decrypt(element *big.Int) *big.Int {
+	return key.multiply(element, key.secretInv)
+}
+
+// multiply function decodes the point provided, multiplies it by the scalar
+// provided and encodes the result.
+func (key *Key) multiply(element, scalar *big.Int) *big.Int {
+	x, y, err := Decode(element)
+	if err != nil {
+		return nil
+	}
+
+	x, y = key.curve.ScalarMult(x, y, scalar.Bytes())
+	return Encode(x, y)
+}
+
+// Prime function returns the prime of the field of the curve.
+func Prime() *big.Int {
+	return new(big.Int).Set(elliptic.P256().Params().P)
+}
+
+// HashToElement function hashes the item provided to a point of the curve
+// using DefaultDST and returns it encoded.
+func HashToElement(item []byte) (*big.Int, error) {
+	x, y, err := HashToCurve(item, []byte(DefaultDST))
+	if err != nil {
+		return nil, err
+	}
+	return Encode(x, y), nil
+}
+
+// Encode function encodes the point provided into a *big.Int using its
+// compressed SEC 1 representation.
+func Encode(x, y *big.Int) *big.Int {
+	return new(big.Int).SetBytes(elliptic.MarshalCompressed(elliptic.P256(), x, y))
+}
+
+// Decode function decodes the point encoded with Encode, checking that it
+// belongs to the curve. It returns an error if it is not a valid point.
+func Decode(element *big.Int) (x, y *big.Int, err error) {
+	if element == nil || element.Sign() <= 0 || (element.BitLen()+7)/8 > ElementSize {
+		return nil, nil, errors.New("invalid encoded point")
+	}
+
+	var data []byte = element.FillBytes(make([]byte, ElementSize))
+	if x, y = elliptic.UnmarshalCompressed(elliptic.P256(), data); x == nil {
+		return nil, nil, errors.New("invalid encoded point")
+	}
+	return x, y, nil
+}
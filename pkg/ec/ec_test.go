@@ -0,0 +1,56 @@
+package ec
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	keyA, err := NewKey()
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	keyB, _ := NewKey()
+
+	message, err := HashToElement([]byte("hello world"))
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if message.BitLen() > ElementSize*8 {
+		t.Fatalf("expected %d bytes element, got %d bits", ElementSize, message.BitLen())
+	}
+
+	var encrypted = keyA.Encrypt(message)
+	if encrypted.Cmp(message) == 0 {
+		t.Fatal("expected encrypted element different to the message")
+	} else if decrypted := keyA.Decrypt(encrypted); decrypted.Cmp(message) != 0 {
+		t.Fatalf("expected %s, got %s", message, decrypted)
+	}
+
+	var ab, ba = keyB.Encrypt(keyA.Encrypt(message)), keyA.Encrypt(keyB.Encrypt(message))
+	if ab.Cmp(ba) != 0 {
+		t.Fatal("expected commutative encryption, got different results")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	element, _ := HashToElement([]byte("hello world"))
+	if _, _, err := Decode(element); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	key, _ := NewKey()
+	var invalid = []*big.Int{
+		nil,
+		big.NewInt(0),
+		big.NewInt(-1),
+		new(big.Int).Lsh(element, 8),
+		new(big.Int).Add(element, new(big.Int).Lsh(big.NewInt(2), 256)),
+	}
+	for _, input := range invalid {
+		if _, _, err := Decode(input); err == nil {
+			t.Errorf("expected error for %v, got nil", input)
+		} else if result := key.Encrypt(input); result != nil {
+			t.Errorf("expected nil for %v, got %s", input, result)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package ec
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// Suite contains the identifier of the hash-to-curve suite implemented by
+// HashToCurve, according to RFC 9380.
+const Suite = "P256_XMD:SHA-256_SSWU_RO_"
+
+// DefaultDST contains the domain separation tag used to hash items to the
+// curve.
+const DefaultDST = "gopsi-V01-CS01-with-" + Suite
+
+// Parameters of the suite: the security parameter in bytes (L) and the
+// Simplified SWU constant Z of P-256.
+const fieldElementLength = 48
+
+var sswuZ = big.NewInt(-10)
+
+// HashToCurve function hashes the message provided to a point of the P-256
+// curve using the domain separation tag provided, following the
+// P256_XMD:SHA-256_SSWU_RO_ suite of RFC 9380
+// (https://www.rfc-editor.org/rfc/rfc9380): it hashes the message to two field
+// elements with expand_message_xmd, maps each one to the curve with the
+// Simplified SWU method and adds the resulting points. The cofactor of P-256
+// is 1, so no cofactor clearing is required. The implementation is not
+// constant-time.
+func HashToCurve(msg, dst []byte) (x, y *big.Int, err error) {
+	if len(dst) == 0 || len(dst) > 255 {
+		return nil, nil, errors.New("invalid domain separation tag")
+	}
+
+	var curve = elliptic.P256()
+	var p *big.Int = curve.Params().P
+	var uniform []byte = expandMessageXMD(msg, dst, 2*fieldElementLength)
+
+	var u0 = new(big.Int).SetBytes(uniform[:fieldElementLength])
+	var u1 = new(big.Int).SetBytes(uniform[fieldElementLength:])
+	x0, y0 := mapToCurveSSWU(u0.Mod(u0, p))
+	x1, y1 := mapToCurveSSWU(u1.Mod(u1, p))
+
+	x, y = curve.Add(x0, y0, x1, y1)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, nil, errors.New("message hashed to the point at infinity")
+	}
+	return x, y, nil
+}
+
+// expandMessageXMD function implements expand_message_xmd (RFC 9380, section
+// 5.3.1) with SHA-256, returning the number of uniform bytes provided.
+func expandMessageXMD(msg, dst []byte, length int) []byte {
+	var ell int = (length + sha256.Size - 1) / sha256.Size
+	var dstPrime []byte = append(append([]byte{}, dst...), byte(len(dst)))
+
+	// b_0 = H(Z_pad || msg || l_i_b_str || I2OSP(0, 1) || DST_prime)
+	var hash = sha256.New()
+	hash.Write(make([]byte, hash.BlockSize()))
+	hash.Write(msg)
+	hash.Write([]byte{byte(length >> 8), byte(length), 0})
+	hash.Write(dstPrime)
+	var b0 []byte = hash.Sum(nil)
+
+	// b_i = H(strxor(b_0, b_(i-1)) || I2OSP(i, 1) || DST_prime)
+	var uniform []byte = make([]byte, 0, ell*sha256.Size)
+	var prev []byte = make([]byte, sha256.Size)
+	for i := 1; i <= ell; i++ {
+		var chained []byte = make([]byte, sha256.Size)
+		for j := range chained {
+			chained[j] = b0[j] ^ prev[j]
+		}
+
+		hash.Reset()
+		hash.Write(chained)
+		hash.Write([]byte{byte(i)})
+		hash.Write(dstPrime)
+		prev = hash.Sum(nil)
+		uniform = append(uniform, prev...)
+	}
+	return uniform[:length]
+}
+
+// mapToCurveSSWU function maps the field element provided to a point of the
+// P-256 curve using the Simplified Shallue-van de Woestijne-Ulas method (RFC
+// 9380, section 6.6.2), with A = -3 and Z = -10.
+func mapToCurveSSWU(u *big.Int) (x, y *big.Int) {
+	var params = elliptic.P256().Params()
+	var p, b = params.P, params.B
+	var a = new(big.Int).Sub(p, big.NewInt(3))
+	var z = new(big.Int).Mod(sswuZ, p)
+
+	// tv1 = inv0(Z^2 * u^4 + Z * u^2)
+	var u2 = mulMod(u, u, p)
+	var zu2 = mulMod(z, u2, p)
+	var tv1 = new(big.Int).Add(mulMod(zu2, zu2, p), zu2)
+	tv1.Mod(tv1, p)
+	if tv1.Sign() != 0 {
+		tv1.ModInverse(tv1, p)
+	}
+
+	// x1 = (-B / A) * (1 + tv1), or x1 = B / (Z * A) if tv1 == 0
+	var x1 *big.Int
+	if tv1.Sign() == 0 {
+		x1 = mulMod(b, new(big.Int).ModInverse(mulMod(z, a, p), p), p)
+	} else {
+		var negB = new(big.Int).Sub(p, b)
+		x1 = mulMod(negB, new(big.Int).ModInverse(a, p), p)
+		x1 = mulMod(x1, new(big.Int).Add(tv1, big.NewInt(1)), p)
+	}
+
+	// gx1 = x1^3 + A * x1 + B; if it is square, x = x1 and y = sqrt(gx1),
+	// otherwise x = Z * u^2 * x1 and y = sqrt(gx2).
+	if y = sqrtMod(curveEquation(x1, a, b, p), p); y != nil {
+		x = x1
+	} else {
+		x = mulMod(zu2, x1, p)
+		y = sqrtMod(curveEquation(x, a, b, p), p)
+	}
+
+	// Fix the sign of y to match the sign of u.
+	if u.Bit(0) != y.Bit(0) {
+		y.Sub(p, y)
+	}
+	return x, y
+}
+
+// curveEquation function returns x^3 + A * x + B (mod p).
+func curveEquation(x, a, b, p *big.Int) *big.Int {
+	var result = mulMod(mulMod(x, x, p), x, p)
+	result.Add(result, mulMod(a, x, p))
+	result.Add(result, b)
+	return result.Mod(result, p)
+}
+
+// sqrtMod function returns the square root of x (mod p) for p = 3 (mod 4), or
+// nil if x is not a square.
+func sqrtMod(x, p *big.Int) *big.Int {
+	var exp = new(big.Int).Add(p, big.NewInt(1))
+	exp.Rsh(exp, 2)
+
+	var root = new(big.Int).Exp(x, exp, p)
+	if mulMod(root, root, p).Cmp(x) != 0 {
+		return nil
+	}
+	return root
+}
+
+// mulMod function returns a * b (mod p).
+func mulMod(a, b, p *big.Int) *big.Int {
+	var result = new(big.Int).Mul(a, b)
+	return result.Mod(result, p)
+}
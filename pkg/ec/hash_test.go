@@ -0,0 +1,43 @@
+package ec
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"testing"
+)
+
+// Test vectors from RFC 9380, appendix J.1.1 (P256_XMD:SHA-256_SSWU_RO_).
+func TestHashToCurve(t *testing.T) {
+	var dst = []byte("QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_")
+	var vectors = []struct {
+		msg, x, y string
+	}{
+		{
+			"",
+			"2c15230b26dbc6fc9a37051158c95b79656e17a1a920b11394ca91c44247d3e4",
+			"8a7a74985cc5c776cdfe4b1f19884970453912e9d31528c060be9ab5c43e8415",
+		},
+		{
+			"abc",
+			"0bb8b87485551aa43ed54f009230450b492fead5f1cc91658775dac4a3388a0f",
+			"5c41b3d0731a27a7b14bc0bf0ccded2d8751f83493404c84a88e71ffd424212e",
+		},
+	}
+
+	for _, vector := range vectors {
+		x, y, err := HashToCurve([]byte(vector.msg), dst)
+		if err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if got := fmt.Sprintf("%064x", x); got != vector.x {
+			t.Errorf("msg %q: expected x %s, got %s", vector.msg, vector.x, got)
+		} else if got := fmt.Sprintf("%064x", y); got != vector.y {
+			t.Errorf("msg %q: expected y %s, got %s", vector.msg, vector.y, got)
+		} else if !elliptic.P256().IsOnCurve(x, y) {
+			t.Errorf("msg %q: expected point on curve", vector.msg)
+		}
+	}
+
+	if _, _, err := HashToCurve([]byte("abc"), nil); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
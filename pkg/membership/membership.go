@@ -0,0 +1,24 @@
+// Package membership defines the interface shared by the membership filters
+// used to prepare the intersection, such as bloomfilter.BloomFilter,
+// bloomfilter.CountingFilter or cuckoofilter.CuckooFilter, so they can be used
+// and stored interchangeably.
+package membership
+
+import "errors"
+
+// ErrUnsupported is returned by the filters that do not support an operation,
+// such as removing items from a bloomfilter.BloomFilter.
+var ErrUnsupported = errors.New("membership: operation not supported")
+
+// Filter interface defines a set of items that can be tested for membership,
+// probably with false positives but never with false negatives. Add returns an
+// error if any item cannot be added, for example, because the filter is full.
+// Remove deletes items that were added before, or returns ErrUnsupported if
+// the filter does not support it. MarshalBinary encodes the filter to be
+// stored or sent to another party. Test must be safe for concurrent use.
+type Filter interface {
+	Add(items ...[]byte) error
+	Test(item []byte) bool
+	Remove(items ...[]byte) error
+	MarshalBinary() ([]byte, error)
+}
@@ -0,0 +1,161 @@
+package psihttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+)
+
+// Error struct contains the status code and the message of an error returned
+// by the Server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+// Error function returns the string representation of the Error.
+func (err *Error) Error() string {
+	return fmt.Sprintf("psihttp: server error (%d): %s", err.StatusCode, err.Message)
+}
+
+// Client struct contains the parameters to request intersections to a Server
+// deployed on BaseURL, using the HTTPClient provided (or the default one).
+// Response bodies are limited to MaxBodySize bytes.
+type Client struct {
+	BaseURL     string
+	HTTPClient  *http.Client
+	MaxBodySize int64
+}
+
+// NewClient function instances a Client to the Server deployed on the base URL
+// provided.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		HTTPClient:  http.DefaultClient,
+		MaxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// Intersect function computes the intersection between the data provided and
+// the Server data, performing every protocol step over a new session with the
+// initialized client.Client provided, which must trust the Server identity
+// (read more in client.WithPeerIdentity). It returns the common items.
+func (c *Client) Intersect(ctx context.Context, psiClient *client.Client, data []string) ([]string, error) {
+	var session struct {
+		ID string `json:"id"`
+	}
+	body, err := c.do(ctx, http.MethodPost, "/sessions", nil)
+	if err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(body, &session); err != nil {
+		return nil, err
+	}
+	var path string = "/sessions/" + session.ID
+
+	// Agree the common prime.
+	proposal, err := psiClient.ProposeParams()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.do(ctx, http.MethodPost, path+"/params", proposal)
+	if err != nil {
+		return nil, err
+	}
+	confirmation, err := psiClient.AcceptParamsContext(ctx, response)
+	if err != nil {
+		return nil, err
+	} else if _, err = c.do(ctx, http.MethodPost, path+"/confirm", confirmation); err != nil {
+		return nil, err
+	}
+	codec, err := psiClient.Codec()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the server encrypted set and re-encrypt it.
+	if body, err = c.do(ctx, http.MethodGet, path+"/set", nil); err != nil {
+		return nil, err
+	}
+	extSet, err := codec.Unmarshal(body)
+	if err != nil {
+		return nil, err
+	}
+	reEncrypted, err := psiClient.EncryptExtContext(ctx, extSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upload the own encrypted set and the re-encrypted server set.
+	encrypted, err := psiClient.EncryptContext(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	var writer = codec.NewWriter(&buf)
+	if err = writer.WriteBatch(encrypted); err != nil {
+		return nil, err
+	} else if err = writer.WriteBatch(reEncrypted); err != nil {
+		return nil, err
+	} else if _, err = c.do(ctx, http.MethodPost, path+"/set", buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// Fetch and parse the intersection result.
+	if body, err = c.do(ctx, http.MethodGet, path+"/result", nil); err != nil {
+		return nil, err
+	}
+	common, err := codec.Unmarshal(body)
+	if err != nil {
+		return nil, err
+	} else if len(common) == 0 {
+		return []string{}, nil
+	}
+	return psiClient.ParseIntersectionContext(ctx, common)
+}
+
+// do function performs a request to the Server with the method, path and body
+// provided, returning the response body. It returns an *Error if the Server
+// responds with an error status code.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	} else if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	var httpClient = c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var limit = c.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var resErr struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(resBody, &resErr)
+		return nil, &Error{StatusCode: res.StatusCode, Message: resErr.Error}
+	}
+	return resBody, nil
+}
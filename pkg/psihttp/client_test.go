@@ -0,0 +1,63 @@
+package psihttp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+)
+
+func TestIntersect(t *testing.T) {
+	// The bloom filter could include false positives, so only check that the
+	// common item is the first one of the results.
+	serverPub, serverKey, _ := ed25519.GenerateKey(rand.Reader)
+	var server = NewServer([]string{"bar", "hello world"})
+	server.ClientOptions = append(server.ClientOptions, client.WithIdentity(serverKey))
+	var ts = httptest.NewServer(server)
+	defer ts.Close()
+
+	psiClient, _ := client.Init(client.WithPeerIdentity(serverPub))
+	result, err := NewClient(ts.URL).Intersect(context.Background(), psiClient, []string{"hello world", "foo"})
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if len(result) == 0 || result[0] != "hello world" {
+		t.Fatalf("expected [hello world], got %v", result)
+	} else if len(server.sessions) != 0 {
+		t.Fatalf("expected no sessions, got %d", len(server.sessions))
+	}
+
+	var srvErr *Error
+	psiClient, _ = client.Init(client.WithPeerIdentity(serverPub))
+	if _, err = NewClient(ts.URL+"/unknown").Intersect(context.Background(), psiClient, []string{"foo"}); !errors.As(err, &srvErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	} else if srvErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d", http.StatusNotFound, srvErr.StatusCode)
+	}
+}
+
+func TestIntersectTrustedServer(t *testing.T) {
+	serverPub, serverKey, _ := ed25519.GenerateKey(rand.Reader)
+	var server = NewServer([]string{"hello world"})
+	server.ClientOptions = append(server.ClientOptions, client.WithIdentity(serverKey))
+	var ts = httptest.NewServer(server)
+	defer ts.Close()
+
+	psiClient, _ := client.Init(client.WithRSABits(0), client.WithPeerIdentity(serverPub))
+	result, err := NewClient(ts.URL).Intersect(context.Background(), psiClient, []string{"hello world"})
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if len(result) != 1 || result[0] != "hello world" {
+		t.Fatalf("expected [hello world], got %v", result)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	psiClient, _ = client.Init(client.WithRSABits(0), client.WithPeerIdentity(otherPub))
+	if _, err = NewClient(ts.URL).Intersect(context.Background(), psiClient, []string{"hello world"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,407 @@
+// Package psihttp exposes the client.Client protocol steps as REST endpoints,
+// allowing to compute a private set intersection between services over HTTP.
+// The Server plays the responder role with its own data, keeping a separate
+// client.Client (and cipher) for each session, while the Client plays the
+// initiator role and gets the intersection result. The endpoints are:
+//
+//	POST /sessions               creates a session, returns {"id": "..."}
+//	POST /sessions/{id}/params   receives the client parameters proposal and
+//	                             returns the signed common prime
+//	POST /sessions/{id}/confirm  receives the client confirmation of the
+//	                             common prime
+//	GET  /sessions/{id}/set      returns the server encrypted set
+//	POST /sessions/{id}/set      receives the client encrypted set and the
+//	                             server set re-encrypted by the client
+//	GET  /sessions/{id}/result   returns the intersection result
+//
+// The common prime is agreed using the client.Client parameters agreement
+// (read more in client.Client.ProposeParams), so the server can be configured
+// with a persistent identity or trusted peer through the ClientOptions.
+// Encrypted sets are encoded using the wire package, while errors are returned
+// as JSON objects with an "error" field.
+package psihttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+	"github.com/lucasmenendez/gopsi/pkg/wire"
+)
+
+// Default values of the Server parameters.
+const (
+	DefaultTTL         = 5 * time.Minute
+	DefaultMaxBodySize = 1 << 28
+	DefaultMaxSessions = 1024
+)
+
+const contentType = "application/octet-stream"
+
+// session struct contains the state of a single intersection: the server
+// client instance, the codec of its common prime, its encrypted set, the
+// intersection result and the last time that it was used.
+type session struct {
+	mtx       sync.Mutex
+	client    *client.Client
+	codec     *wire.Codec
+	encrypted []byte
+	result    []byte
+	lastSeen  time.Time
+}
+
+// Server struct implements an http.Handler that computes the intersection
+// between its data and the data of the clients. The client.Client of each
+// session is initialized with ClientOptions. Sessions not used for longer than
+// TTL are removed (read more in Server.sweep), up to MaxSessions sessions can
+// be alive at the same time, and request bodies are limited to MaxBodySize
+// bytes.
+type Server struct {
+	ClientOptions []client.Option
+	TTL           time.Duration
+	MaxBodySize   int64
+	MaxSessions   int
+
+	data      []string
+	mtx       sync.Mutex
+	sessions  map[string]*session
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewServer function instances a Server with the data provided and the
+// default parameters. The clients of the sessions are initialized without RSA
+// keys, which are not required by the parameters agreement, and accept the
+// parameters proposed by any client (read more in client.WithAnyPeer): the
+// server does not know its clients, but it signs its responses, so the clients
+// must trust its identity.
+func NewServer(data []string) *Server {
+	return &Server{
+		ClientOptions: []client.Option{client.WithRSABits(0), client.WithAnyPeer()},
+		TTL:           DefaultTTL,
+		MaxBodySize:   DefaultMaxBodySize,
+		MaxSessions:   DefaultMaxSessions,
+		data:          data,
+		sessions:      make(map[string]*session),
+		now:           time.Now,
+	}
+}
+
+// ServeHTTP function routes the requests to the session endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var parts = strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "sessions" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	} else if len(parts) == 1 {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		s.createSession(w)
+		return
+	} else if len(parts) == 2 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	sess, ok := s.session(parts[1])
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+	sess.mtx.Lock()
+	defer sess.mtx.Unlock()
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodySize())
+	switch {
+	case parts[2] == "params" && r.Method == http.MethodPost:
+		s.exchangeParams(w, r, sess)
+	case parts[2] == "confirm" && r.Method == http.MethodPost:
+		s.confirmParams(w, r, parts[1], sess)
+	case parts[2] == "set" && r.Method == http.MethodGet:
+		s.getSet(w, sess)
+	case parts[2] == "set" && r.Method == http.MethodPost:
+		s.postSet(w, r, parts[1], sess)
+	case parts[2] == "result" && r.Method == http.MethodGet:
+		s.getResult(w, parts[1], sess)
+	case parts[2] == "params" || parts[2] == "confirm" || parts[2] == "set" || parts[2] == "result":
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// createSession function creates a new session with a new client instance and
+// returns its ID. It removes the expired sessions first, and returns an error
+// if the server already has the maximum number of sessions.
+func (s *Server) createSession(w http.ResponseWriter) {
+	if !s.hasRoom() {
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many sessions"))
+		return
+	}
+
+	var rawID []byte = make([]byte, 16)
+	if _, err := rand.Read(rawID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	psiClient, err := client.Init(s.ClientOptions...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Check the limit again, other sessions could be created meanwhile.
+	var id string = hex.EncodeToString(rawID)
+	s.mtx.Lock()
+	if len(s.sessions) >= s.maxSessions() {
+		s.mtx.Unlock()
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many sessions"))
+		return
+	}
+	s.sessions[id] = &session{client: psiClient, lastSeen: s.now()}
+	s.mtx.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// exchangeParams function receives the client parameters proposal and
+// generates the signed response with the common prime.
+func (s *Server) exchangeParams(w http.ResponseWriter, r *http.Request, sess *session) {
+	if sess.codec != nil {
+		writeError(w, http.StatusConflict, errors.New("common prime already defined"))
+		return
+	}
+
+	proposal, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	response, err := sess.client.RespondParamsContext(r.Context(), proposal)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// confirmParams function receives the client confirmation of the common prime
+// and encrypts the server data with it. The session is only updated once the
+// server data is encrypted and encoded. The confirmation cannot be retried once
+// the client has stored the common prime, so if the encryption fails, for
+// example, because the request is canceled, the session is removed.
+func (s *Server) confirmParams(w http.ResponseWriter, r *http.Request, id string, sess *session) {
+	if sess.codec != nil {
+		writeError(w, http.StatusConflict, errors.New("common prime already defined"))
+		return
+	}
+
+	confirmation, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	} else if err = sess.client.ConfirmParams(confirmation); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	codec, err := sess.client.Codec()
+	if err != nil {
+		s.removeSession(id)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	encrypted, err := sess.client.EncryptContext(r.Context(), s.data)
+	if err != nil {
+		s.removeSession(id)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	payload, err := codec.Marshal(encrypted)
+	if err != nil {
+		s.removeSession(id)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sess.codec, sess.encrypted = codec, payload
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSet function returns the server encrypted set.
+func (s *Server) getSet(w http.ResponseWriter, sess *session) {
+	if sess.encrypted == nil {
+		writeError(w, http.StatusConflict, errors.New("common prime not defined"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(sess.encrypted)
+}
+
+// postSet function receives the client encrypted set and the server set
+// re-encrypted by the client, and computes the intersection between them. Both
+// sets are validated before preparing the intersection, so an invalid request
+// can be retried. If the intersection fails once it is prepared, for example,
+// because the request is canceled, the session is removed.
+func (s *Server) postSet(w http.ResponseWriter, r *http.Request, id string, sess *session) {
+	if sess.codec == nil {
+		writeError(w, http.StatusConflict, errors.New("common prime not defined"))
+		return
+	} else if sess.result != nil {
+		writeError(w, http.StatusConflict, errors.New("set already received"))
+		return
+	}
+
+	var reader = sess.codec.NewReader(r.Body)
+	extSet, err := reader.ReadBatch()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	reEncrypted, err := reader.ReadBatch()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	} else if _, err = reader.ReadBatch(); err != io.EOF {
+		writeError(w, http.StatusBadRequest, errors.New("unexpected batch"))
+		return
+	}
+
+	if err = sess.client.ValidateItemsContext(r.Context(), extSet); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	} else if err = sess.client.PrepareIntersectionContext(r.Context(), reEncrypted); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var common [][]*big.Int
+	if len(extSet) > 0 {
+		if common, err = sess.client.GetIntersectionContext(r.Context(), extSet); err != nil {
+			s.removeSession(id)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if sess.result, err = sess.codec.Marshal(common); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getResult function returns the intersection result and removes the session.
+func (s *Server) getResult(w http.ResponseWriter, id string, sess *session) {
+	if sess.result == nil {
+		writeError(w, http.StatusConflict, errors.New("intersection not computed"))
+		return
+	}
+
+	s.removeSession(id)
+	w.Header().Set("Content-Type", contentType)
+	w.Write(sess.result)
+}
+
+// session function returns the session with the ID provided, updating its
+// last use time. If the session is expired, it is removed instead. It also
+// removes the other expired sessions if they were not removed during the last
+// TTL (read more in Server.sweep).
+func (s *Server) session(id string) (*session, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var now time.Time = s.now()
+	if now.Sub(s.lastSweep) > s.ttl() {
+		s.sweep(now)
+	}
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	} else if now.Sub(sess.lastSeen) > s.ttl() {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	sess.lastSeen = now
+	return sess, true
+}
+
+// removeSession function removes the session with the ID provided.
+func (s *Server) removeSession(id string) {
+	s.mtx.Lock()
+	delete(s.sessions, id)
+	s.mtx.Unlock()
+}
+
+// hasRoom function removes the expired sessions and checks that the server has
+// less sessions than the maximum.
+func (s *Server) hasRoom() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sweep(s.now())
+	return len(s.sessions) < s.maxSessions()
+}
+
+// sweep function removes the sessions not used for longer than TTL at the
+// time provided. It goes through every session, so it runs when a session is
+// created and, at most once per TTL, when a session is looked up, which
+// removes the abandoned sessions of a server that does not create new ones
+// without slowing down every lookup. It must be called holding the server
+// mutex.
+func (s *Server) sweep(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) > s.ttl() {
+			delete(s.sessions, id)
+		}
+	}
+	s.lastSweep = now
+}
+
+// ttl function returns the time after which the unused sessions expire.
+func (s *Server) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+// maxSessions function returns the maximum number of sessions alive at the
+// same time.
+func (s *Server) maxSessions() int {
+	if s.MaxSessions <= 0 {
+		return DefaultMaxSessions
+	}
+	return s.MaxSessions
+}
+
+// maxBodySize function returns the maximum size of the request bodies.
+func (s *Server) maxBodySize() int64 {
+	if s.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return s.MaxBodySize
+}
+
+// writeError function writes the error provided as a JSON object with the
+// status code provided.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,219 @@
+package psihttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+)
+
+func createSession(t *testing.T, server *Server) string {
+	var rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sessions", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	var session struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	return session.ID
+}
+
+func TestServerRoutes(t *testing.T) {
+	var server = NewServer([]string{"hello world"})
+	var id string = createSession(t, server)
+
+	var cases = []struct {
+		method, path string
+		body         []byte
+		expected     int
+	}{
+		{http.MethodGet, "/sessions", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/unknown", nil, http.StatusNotFound},
+		{http.MethodGet, "/sessions/unknown/set", nil, http.StatusNotFound},
+		{http.MethodGet, "/sessions/" + id + "/unknown", nil, http.StatusNotFound},
+		{http.MethodGet, "/sessions/" + id + "/params", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/sessions/" + id + "/confirm", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/sessions/" + id + "/confirm", []byte("bad confirmation"), http.StatusBadRequest},
+		{http.MethodGet, "/sessions/" + id + "/set", nil, http.StatusConflict},
+		{http.MethodPost, "/sessions/" + id + "/set", []byte{}, http.StatusConflict},
+		{http.MethodGet, "/sessions/" + id + "/result", nil, http.StatusConflict},
+		{http.MethodPost, "/sessions/" + id + "/params", []byte("bad proposal"), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		var rec = httptest.NewRecorder()
+		var req = httptest.NewRequest(c.method, c.path, bytes.NewReader(c.body))
+		if server.ServeHTTP(rec, req); rec.Code != c.expected {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.expected, rec.Code)
+		}
+	}
+}
+
+func TestServerExpiration(t *testing.T) {
+	var now = time.Now()
+	var server = NewServer([]string{"hello world"})
+	server.now = func() time.Time { return now }
+	var id string = createSession(t, server)
+
+	now = now.Add(server.TTL + time.Second)
+	var rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/"+id+"/set", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d", http.StatusNotFound, rec.Code)
+	} else if len(server.sessions) != 0 {
+		t.Fatalf("expected no sessions, got %d", len(server.sessions))
+	}
+}
+
+func TestServerSweep(t *testing.T) {
+	var now = time.Now()
+	var server = NewServer([]string{"hello world"})
+	server.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		createSession(t, server)
+	}
+
+	// The expired sessions are removed when a session is created, without
+	// looking them up.
+	now = now.Add(server.TTL + time.Second)
+	var id string = createSession(t, server)
+	if _, ok := server.sessions[id]; !ok || len(server.sessions) != 1 {
+		t.Fatalf("expected only the new session, got %d", len(server.sessions))
+	}
+}
+
+func TestServerLookupSweep(t *testing.T) {
+	var now = time.Now()
+	var server = NewServer([]string{"hello world"})
+	server.now = func() time.Time { return now }
+	var id string = createSession(t, server)
+	for i := 0; i < 3; i++ {
+		createSession(t, server)
+	}
+
+	// The lookups of a session remove the other expired sessions, even if no
+	// session is created.
+	var get = func() {
+		var rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/"+id+"/result", nil))
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected %d, got %d", http.StatusConflict, rec.Code)
+		}
+	}
+	now = now.Add(server.TTL / 2)
+	if get(); len(server.sessions) != 4 {
+		t.Fatalf("expected 4 sessions, got %d", len(server.sessions))
+	}
+	now = now.Add(server.TTL/2 + time.Second)
+	if get(); len(server.sessions) != 1 {
+		t.Fatalf("expected only the used session, got %d", len(server.sessions))
+	}
+}
+
+func TestServerMaxSessions(t *testing.T) {
+	var now = time.Now()
+	var server = NewServer([]string{"hello world"})
+	server.now = func() time.Time { return now }
+	server.MaxSessions = 2
+	createSession(t, server)
+	createSession(t, server)
+
+	var rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sessions", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	} else if len(server.sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(server.sessions))
+	}
+
+	now = now.Add(server.TTL + time.Second)
+	createSession(t, server)
+}
+
+func TestServerConfirmFailure(t *testing.T) {
+	serverPub, serverKey, _ := ed25519.GenerateKey(rand.Reader)
+	var server = NewServer([]string{"hello world"})
+	server.ClientOptions = append(server.ClientOptions, client.WithIdentity(serverKey))
+	var path string = "/sessions/" + createSession(t, server)
+
+	psiClient, _ := client.Init(client.WithRSABits(0), client.WithPeerIdentity(serverPub))
+	proposal, _ := psiClient.ProposeParams()
+	var rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path+"/params", bytes.NewReader(proposal)))
+	confirmation, err := psiClient.AcceptParams(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+
+	// If the server data is not encrypted, for example, because the request
+	// is canceled, the session can not be completed, so it is removed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	var req = httptest.NewRequest(http.MethodPost, path+"/confirm", bytes.NewReader(confirmation)).WithContext(ctx)
+	if server.ServeHTTP(rec, req); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected %d, got %d", http.StatusInternalServerError, rec.Code)
+	} else if len(server.sessions) != 0 {
+		t.Fatalf("expected no sessions, got %d", len(server.sessions))
+	}
+}
+
+func TestServerPostSetRetry(t *testing.T) {
+	serverPub, serverKey, _ := ed25519.GenerateKey(rand.Reader)
+	var server = NewServer([]string{"hello world", "foo"})
+	server.ClientOptions = append(server.ClientOptions, client.WithIdentity(serverKey), client.WithEncoding(client.HashEncoding))
+	var path string = "/sessions/" + createSession(t, server)
+	var do = func(method, path string, body []byte) *httptest.ResponseRecorder {
+		var rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		return rec
+	}
+
+	psiClient, _ := client.Init(client.WithRSABits(0), client.WithPeerIdentity(serverPub), client.WithEncoding(client.HashEncoding))
+	proposal, _ := psiClient.ProposeParams()
+	confirmation, err := psiClient.AcceptParams(do(http.MethodPost, path+"/params", proposal).Body.Bytes())
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if rec := do(http.MethodPost, path+"/confirm", confirmation); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	codec, _ := psiClient.Codec()
+	extSet, _ := codec.Unmarshal(do(http.MethodGet, path+"/set", nil).Body.Bytes())
+	reEncrypted, _ := psiClient.EncryptExt(extSet)
+	encrypted, _ := psiClient.Encrypt([]string{"hello world"})
+
+	var body = func(batches ...[][]*big.Int) []byte {
+		var buf bytes.Buffer
+		var writer = codec.NewWriter(&buf)
+		for _, batch := range batches {
+			writer.WriteBatch(batch)
+		}
+		return buf.Bytes()
+	}
+
+	// An invalid client set, with two elements in a hash encoded item, must not
+	// prepare the intersection, so the request can be retried.
+	var invalid = [][]*big.Int{{encrypted[0][0], encrypted[0][0]}}
+	if rec := do(http.MethodPost, path+"/set", body(invalid, reEncrypted)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rec.Code)
+	} else if rec = do(http.MethodPost, path+"/set", body(encrypted, reEncrypted)); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+
+	common, _ := codec.Unmarshal(do(http.MethodGet, path+"/result", nil).Body.Bytes())
+	if result, err := psiClient.ParseIntersection(common); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if len(result) != 1 || result[0] != "hello world" {
+		t.Fatalf("expected [hello world], got %v", result)
+	}
+}
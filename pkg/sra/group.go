@@ -0,0 +1,220 @@
+package sra
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// Group struct contains a safe prime P = 2Q + 1, where Q is also prime and is
+// the order of the subgroup of quadratic residues modulo P. Working into this
+// subgroup avoids small subgroups, so the Decisional Diffie-Hellman assumption
+// required by the commutative encryption of PSI holds. Read more about these
+// groups in RFC 3526 (https://www.rfc-editor.org/rfc/rfc3526) and RFC 7919
+// (https://www.rfc-editor.org/rfc/rfc7919).
+type Group struct {
+	Name string
+	P    *big.Int
+	Q    *big.Int
+}
+
+// Well-known safe prime groups, from RFC 3526 (MODP) and RFC 7919 (FFDHE).
+var (
+	MODP2048  = mustGroup("modp2048", modp2048Hex)
+	MODP3072  = mustGroup("modp3072", modp3072Hex)
+	MODP4096  = mustGroup("modp4096", modp4096Hex)
+	FFDHE2048 = mustGroup("ffdhe2048", ffdhe2048Hex)
+	FFDHE3072 = mustGroup("ffdhe3072", ffdhe3072Hex)
+	FFDHE4096 = mustGroup("ffdhe4096", ffdhe4096Hex)
+)
+
+// Groups contains every well-known group supported, indexed by its name.
+var Groups = map[string]*Group{
+	MODP2048.Name:  MODP2048,
+	MODP3072.Name:  MODP3072,
+	MODP4096.Name:  MODP4096,
+	FFDHE2048.Name: FFDHE2048,
+	FFDHE3072.Name: FFDHE3072,
+	FFDHE4096.Name: FFDHE4096,
+}
+
+// NewGroup function instances a Group with the name and the prime provided,
+// checking that the prime is a safe prime. It returns an error if it is not.
+func NewGroup(name string, prime *big.Int) (*Group, error) {
+	if prime == nil || prime.Cmp(big.NewInt(7)) <= 0 || prime.Bit(0) == 0 {
+		return nil, errors.New("invalid safe prime")
+	}
+
+	var order = new(big.Int).Rsh(prime, 1)
+	if !order.ProbablyPrime(20) || !prime.ProbablyPrime(20) {
+		return nil, errors.New("the prime provided is not a safe prime")
+	}
+
+	return &Group{Name: name, P: prime, Q: order}, nil
+}
+
+// GroupByPrime function returns the well-known group with the prime provided,
+// if it exists.
+func GroupByPrime(prime *big.Int) (*Group, bool) {
+	for _, group := range Groups {
+		if group.P.Cmp(prime) == 0 {
+			return group, true
+		}
+	}
+	return nil, false
+}
+
+// Contains function returns if the element provided belongs to the subgroup of
+// quadratic residues of the current group, excluding the identity: x is into
+// the range [2, P-2] and x^Q = 1 (mod P). Since P is a safe prime, it is
+// checked with the Jacobi symbol of x, which is much cheaper than the
+// exponentiation.
+func (g *Group) Contains(x *big.Int) bool {
+	if x == nil || x.Cmp(big.NewInt(1)) <= 0 || x.Cmp(g.P) >= 0 {
+		return false
+	}
+	return big.Jacobi(x, g.P) == 1
+}
+
+// Square function maps the element provided into the subgroup of quadratic
+// residues of the current group, returning x^2 (mod P). The result is not
+// reversible, so it is intended for hashed elements.
+func (g *Group) Square(x *big.Int) *big.Int {
+	return new(big.Int).Exp(x, big.NewInt(2), g.P)
+}
+
+// Encode function maps the small element provided (smaller than Q) into the
+// subgroup of quadratic residues reversibly. Since -1 is not a quadratic
+// residue modulo a safe prime, exactly one of m and P-m belongs to the
+// subgroup, so it returns the one that belongs to it.
+func (g *Group) Encode(m *big.Int) *big.Int {
+	if m.Sign() == 0 || big.Jacobi(m, g.P) == 1 {
+		return new(big.Int).Set(m)
+	}
+	return new(big.Int).Sub(g.P, m)
+}
+
+// Decode function reverses Group.Encode, returning the smallest of x and P-x.
+func (g *Group) Decode(x *big.Int) *big.Int {
+	if x.Cmp(g.Q) <= 0 {
+		return new(big.Int).Set(x)
+	}
+	return new(big.Int).Sub(g.P, x)
+}
+
+// NewGroupKey function calculates an SRA key pair for the subgroup of
+// quadratic residues of the group provided. The encryption key (K) is a
+// random number of the size provided in the range [2, Q-1], and the decryption
+// key (L) is the inverse of K (mod Q), the order of the subgroup. The key only
+// works with elements of the subgroup, for example, encoded with
+// Group.Encode or Group.Square.
+func NewGroupKey(group *Group, size int) (key *SRAKey, err error) {
+	if size < 2 || size >= group.Q.BitLen() {
+		return nil, errors.New("invalid key size for the group")
+	}
+
+	var bigTwo = big.NewInt(2)
+	var max = new(big.Int).Lsh(big.NewInt(1), uint(size-1))
+	key = &SRAKey{prime: group.P}
+	for {
+		// Generate a random number of the size provided setting its top bit.
+		if key.secret, err = rand.Int(rand.Reader, max); err != nil {
+			return nil, err
+		}
+		key.secret.SetBit(key.secret, size-1, 1)
+
+		if key.secret.Cmp(bigTwo) >= 0 && key.secret.Cmp(group.Q) < 0 {
+			break
+		}
+	}
+
+	// Calculate key.secretInv (L) as the inverse of key.secret mod Q.
+	key.secretInv = new(big.Int).ModInverse(key.secret, group.Q)
+	return
+}
+
+// mustGroup function instances a Group with the name and the hex encoded safe
+// prime provided. The primes are not checked to avoid its cost during the
+// package initialization, it is done by the package tests.
+func mustGroup(name, hexPrime string) *Group {
+	var prime, ok = new(big.Int).SetString(hexPrime, 16)
+	if !ok {
+		panic("invalid group prime: " + name)
+	}
+	return &Group{Name: name, P: prime, Q: new(big.Int).Rsh(prime, 1)}
+}
+
+const (
+	modp2048Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF"
+	modp3072Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+	modp4096Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF"
+	ffdhe2048Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF"
+	ffdhe3072Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF"
+	ffdhe4096Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF"
+)
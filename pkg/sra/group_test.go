@@ -0,0 +1,80 @@
+package sra
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	var sizes = map[string]int{
+		"modp2048": 2048, "modp3072": 3072, "modp4096": 4096,
+		"ffdhe2048": 2048, "ffdhe3072": 3072, "ffdhe4096": 4096,
+	}
+	for name, group := range Groups {
+		if group.Name != name {
+			t.Errorf("Expected name '%s', got '%s'", name, group.Name)
+		} else if group.P.BitLen() != sizes[name] {
+			t.Errorf("Expected %d bits, got %d", sizes[name], group.P.BitLen())
+		} else if _, err := NewGroup(name, group.P); err != nil {
+			t.Errorf("Expected safe prime for group '%s', got error: %s", name, err)
+		} else if found, ok := GroupByPrime(group.P); !ok || found != group {
+			t.Errorf("Expected to find group '%s' by its prime", name)
+		}
+	}
+
+	if _, err := NewGroup("invalid", big.NewInt(13)); err == nil {
+		t.Errorf("Expected error for a non safe prime, got nil")
+	} else if _, ok := GroupByPrime(big.NewInt(23)); ok {
+		t.Errorf("Expected unknown group, got one")
+	}
+}
+
+func TestGroupEncoding(t *testing.T) {
+	var group = FFDHE2048
+	for i := int64(1); i < 256; i++ {
+		var m = big.NewInt(i)
+		var encoded = group.Encode(m)
+		if i > 1 && !group.Contains(encoded) {
+			t.Errorf("Expected encoded %d into the subgroup", i)
+		} else if decoded := group.Decode(encoded); decoded.Cmp(m) != 0 {
+			t.Errorf("Expected %d, got %s", i, decoded)
+		}
+	}
+
+	if group.Contains(big.NewInt(1)) || group.Contains(new(big.Int).Sub(group.P, big.NewInt(1))) {
+		t.Errorf("Expected trivial elements out of the subgroup")
+	} else if !group.Contains(group.Square(big.NewInt(12345))) {
+		t.Errorf("Expected squared element into the subgroup")
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	var group = MODP2048
+	if _, err := NewGroupKey(group, 1); err == nil {
+		t.Errorf("Expected error for an invalid size, got nil")
+	} else if _, err := NewGroupKey(group, 2048); err == nil {
+		t.Errorf("Expected error for an invalid size, got nil")
+	}
+
+	keyA, err := NewGroupKey(group, 224)
+	if err != nil {
+		t.Errorf("Expected success generating the key, got error: %s", err)
+		return
+	} else if keyA.secret.BitLen() != 224 {
+		t.Errorf("Expected secret of 224 bits, got %d", keyA.secret.BitLen())
+	}
+	keyB, _ := NewGroupKey(group, 224)
+
+	var message = group.Square(big.NewInt(987654321))
+	var encrypted = keyA.Encrypt(message)
+	if !group.Contains(encrypted) {
+		t.Errorf("Expected encrypted message into the subgroup")
+	} else if decrypted := keyA.Decrypt(encrypted); decrypted.Cmp(message) != 0 {
+		t.Errorf("Expected '%s', got '%s'", message, decrypted)
+	}
+
+	var ab, ba = keyB.Encrypt(keyA.Encrypt(message)), keyA.Encrypt(keyB.Encrypt(message))
+	if ab.Cmp(ba) != 0 {
+		t.Errorf("Expected commutative encryption, got different results")
+	}
+}
@@ -0,0 +1,386 @@
+// Package transport implements the two-party PSI handshake of client.Client
+// over a net.Conn, allowing to compute the intersection between two separate
+// processes. Every message is sent into a frame composed by the message type
+// (1 byte), the payload length (4 bytes, big-endian) and the payload itself.
+// Encrypted batches are encoded using the wire package.
+//
+// The protocol follows the same sequence that the psi_example, but agreeing
+// the common prime with signed parameters instead of RSA (read more in
+// client.Client.ProposeParams):
+//
+//	Initiator                               Responder
+//	ProposeParams()     -- proposal ->
+//	                    <- response -       RespondParams()
+//	AcceptParams()      - confirmation ->   ConfirmParams()
+//	                    <-- set ----        Encrypt()
+//	Encrypt()
+//	EncryptExt()        -- sets --->        PrepareIntersection()
+//	                    <-- result -        GetIntersection()
+//	ParseIntersection()
+//
+// If some of the steps fails, the side that fails sends an error message to
+// its peer with a typed ProtocolError before closing the session.
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+)
+
+// Role type defines the side of the protocol played by a Session.
+type Role int
+
+const (
+	// Initiator role proposes the parameters, re-encrypts the set of the
+	// responder and gets the intersection result.
+	Initiator Role = iota
+	// Responder role generates the common prime and computes the
+	// intersection, without learning it.
+	Responder
+)
+
+// Default values of the Session parameters.
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxFrameSize = 1 << 28
+)
+
+// Message types of the protocol.
+const (
+	msgProposal byte = iota + 1
+	msgParams
+	msgSet
+	msgSets
+	msgResult
+	msgError
+	msgConfirmation
+)
+
+// ErrorCode type identifies the kind of a ProtocolError.
+type ErrorCode uint16
+
+const (
+	// CodeInternal is used when the side that fails can not complete a
+	// protocol step by itself.
+	CodeInternal ErrorCode = iota + 1
+	// CodeUnexpectedMessage is used when a message of other type than the
+	// expected one is received.
+	CodeUnexpectedMessage
+	// CodeInvalidPayload is used when a received message can not be decoded
+	// or processed.
+	CodeInvalidPayload
+	// CodeTimeout is used when a message is not sent or received before the
+	// session timeout.
+	CodeTimeout
+)
+
+// ProtocolError struct describes an error of the protocol, raised locally or
+// received from the peer (Remote).
+type ProtocolError struct {
+	Code    ErrorCode
+	Message string
+	Remote  bool
+}
+
+// Error function returns the string representation of the ProtocolError.
+func (err *ProtocolError) Error() string {
+	var side = "local"
+	if err.Remote {
+		side = "remote"
+	}
+	return fmt.Sprintf("transport: %s error (%d): %s", side, err.Code, err.Message)
+}
+
+// Session struct contains the parameters to run the protocol over a connection
+// using a Client with the role provided. Timeout limits the duration of each
+// message sent or received, including the time waiting for the peer, which
+// computes its next message meanwhile, so it must cover the peer operations
+// with the largest set. The local operations between messages, such as
+// encrypting the own set, are not limited. MaxFrameSize limits the size of the
+// received messages.
+type Session struct {
+	Conn         net.Conn
+	Client       *client.Client
+	Role         Role
+	Timeout      time.Duration
+	MaxFrameSize int
+}
+
+// NewSession function instances a Session with the connection, client and
+// role provided and the default parameters.
+func NewSession(conn net.Conn, c *client.Client, role Role) *Session {
+	return &Session{
+		Conn:         conn,
+		Client:       c,
+		Role:         role,
+		Timeout:      DefaultTimeout,
+		MaxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// Run function performs the full protocol with the data provided according to
+// the session role. The initiator gets the intersection between both sets as
+// result, while the responder gets an empty result. If some step fails, it
+// notifies the error to the peer and returns it. The connection is not closed.
+func (s *Session) Run(data []string) (result []string, err error) {
+	if s.Conn == nil || s.Client == nil {
+		return nil, errors.New("transport: session not initialized")
+	}
+	defer s.Conn.SetDeadline(time.Time{})
+
+	switch s.Role {
+	case Initiator:
+		result, err = s.runInitiator(data)
+	case Responder:
+		err = s.runResponder(data)
+	default:
+		return nil, errors.New("transport: unknown role")
+	}
+
+	// Notify the error to the peer if it was raised locally.
+	var perr *ProtocolError
+	if errors.As(err, &perr) && !perr.Remote {
+		var payload []byte = make([]byte, 2, 2+len(perr.Message))
+		binary.BigEndian.PutUint16(payload, uint16(perr.Code))
+		s.send(msgError, append(payload, perr.Message...))
+	}
+	return
+}
+
+// runInitiator function performs the initiator side of the protocol.
+func (s *Session) runInitiator(data []string) ([]string, error) {
+	proposal, err := s.Client.ProposeParams()
+	if err != nil {
+		return nil, localError(CodeInternal, err)
+	} else if err = s.send(msgProposal, proposal); err != nil {
+		return nil, err
+	}
+
+	response, err := s.receive(msgParams)
+	if err != nil {
+		return nil, err
+	}
+	confirmation, err := s.Client.AcceptParams(response)
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	} else if err = s.send(msgConfirmation, confirmation); err != nil {
+		return nil, err
+	}
+
+	codec, err := s.Client.Codec()
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	}
+
+	// Receive the responder encrypted set and re-encrypt it.
+	payload, err := s.receive(msgSet)
+	if err != nil {
+		return nil, err
+	}
+	extSet, err := codec.Unmarshal(payload)
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	}
+	reEncrypted, err := s.Client.EncryptExt(extSet)
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	}
+
+	// Encrypt the own set and send both sets to the responder.
+	encrypted, err := s.Client.Encrypt(data)
+	if err != nil {
+		return nil, localError(CodeInternal, err)
+	}
+	var buf bytes.Buffer
+	var writer = codec.NewWriter(&buf)
+	if err = writer.WriteBatch(encrypted); err != nil {
+		return nil, localError(CodeInternal, err)
+	} else if err = writer.WriteBatch(reEncrypted); err != nil {
+		return nil, localError(CodeInternal, err)
+	} else if err = s.send(msgSets, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// Receive and parse the intersection result.
+	if payload, err = s.receive(msgResult); err != nil {
+		return nil, err
+	}
+	common, err := codec.Unmarshal(payload)
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	} else if len(common) == 0 {
+		return []string{}, nil
+	}
+
+	result, err := s.Client.ParseIntersection(common)
+	if err != nil {
+		return nil, localError(CodeInvalidPayload, err)
+	}
+	return result, nil
+}
+
+// runResponder function performs the responder side of the protocol.
+func (s *Session) runResponder(data []string) error {
+	proposal, err := s.receive(msgProposal)
+	if err != nil {
+		return err
+	}
+	response, err := s.Client.RespondParams(proposal)
+	if err != nil {
+		return localError(CodeInvalidPayload, err)
+	} else if err = s.send(msgParams, response); err != nil {
+		return err
+	}
+
+	confirmation, err := s.receive(msgConfirmation)
+	if err != nil {
+		return err
+	} else if err = s.Client.ConfirmParams(confirmation); err != nil {
+		return localError(CodeInvalidPayload, err)
+	}
+
+	codec, err := s.Client.Codec()
+	if err != nil {
+		return localError(CodeInternal, err)
+	}
+
+	// Encrypt the own set and send it to the initiator.
+	encrypted, err := s.Client.Encrypt(data)
+	if err != nil {
+		return localError(CodeInternal, err)
+	}
+	payload, err := codec.Marshal(encrypted)
+	if err != nil {
+		return localError(CodeInternal, err)
+	} else if err = s.send(msgSet, payload); err != nil {
+		return err
+	}
+
+	// Receive the initiator encrypted set and the own re-encrypted set.
+	if payload, err = s.receive(msgSets); err != nil {
+		return err
+	}
+	var reader = codec.NewReader(bytes.NewReader(payload))
+	extSet, err := reader.ReadBatch()
+	if err != nil {
+		return localError(CodeInvalidPayload, err)
+	}
+	reEncrypted, err := reader.ReadBatch()
+	if err != nil {
+		return localError(CodeInvalidPayload, err)
+	} else if _, err = reader.ReadBatch(); err != io.EOF {
+		return localError(CodeInvalidPayload, errors.New("unexpected batch"))
+	}
+
+	// Compute the intersection and send it to the initiator.
+	if err = s.Client.PrepareIntersection(reEncrypted); err != nil {
+		return localError(CodeInvalidPayload, err)
+	}
+	var common [][]*big.Int
+	if len(extSet) > 0 {
+		if common, err = s.Client.GetIntersection(extSet); err != nil {
+			return localError(CodeInvalidPayload, err)
+		}
+	}
+	if payload, err = codec.Marshal(common); err != nil {
+		return localError(CodeInternal, err)
+	}
+	return s.send(msgResult, payload)
+}
+
+// send function writes a frame with the message type and the payload provided
+// into the connection, limiting the operation with the session timeout.
+func (s *Session) send(msgType byte, payload []byte) error {
+	s.setDeadline()
+
+	var frame []byte = make([]byte, 5, 5+len(payload))
+	frame[0] = msgType
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	if _, err := s.Conn.Write(append(frame, payload...)); err != nil {
+		return connError(err)
+	}
+	return nil
+}
+
+// receive function reads the next frame from the connection, limiting the
+// operation with the session timeout, and returns its payload. The payload is
+// read into a buffer that grows as its bytes arrive, so a peer cannot make the
+// session allocate the maximum frame size by only sending a frame header. It
+// returns a remote ProtocolError if the peer sends an error message, or a local
+// one if the received message type is not the expected or it exceeds the
+// maximum frame size.
+func (s *Session) receive(expected byte) ([]byte, error) {
+	s.setDeadline()
+
+	var header []byte = make([]byte, 5)
+	if _, err := io.ReadFull(s.Conn, header); err != nil {
+		return nil, connError(err)
+	}
+
+	var maxSize = s.MaxFrameSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	var size uint32 = binary.BigEndian.Uint32(header[1:])
+	if size > uint32(maxSize) {
+		return nil, localError(CodeInvalidPayload, fmt.Errorf("frame of %d bytes too large", size))
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, s.Conn, int64(size)); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, connError(err)
+	}
+	var payload []byte = buf.Bytes()
+
+	switch msgType := header[0]; {
+	case msgType == msgError:
+		if len(payload) < 2 {
+			return nil, localError(CodeInvalidPayload, errors.New("malformed error message"))
+		}
+		return nil, &ProtocolError{
+			Code:    ErrorCode(binary.BigEndian.Uint16(payload)),
+			Message: string(payload[2:]),
+			Remote:  true,
+		}
+	case msgType != expected:
+		err := fmt.Errorf("expected message %d, got %d", expected, msgType)
+		return nil, localError(CodeUnexpectedMessage, err)
+	}
+	return payload, nil
+}
+
+// setDeadline function sets the connection deadline to the session timeout
+// from now.
+func (s *Session) setDeadline() {
+	var timeout = s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	s.Conn.SetDeadline(time.Now().Add(timeout))
+}
+
+// localError function wraps the error provided into a local ProtocolError
+// with the code provided.
+func localError(code ErrorCode, err error) error {
+	return &ProtocolError{Code: code, Message: err.Error()}
+}
+
+// connError function wraps connection timeouts into local ProtocolErrors to
+// notify them to the peer. Other connection errors are returned as they are.
+func connError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return localError(CodeTimeout, err)
+	}
+	return err
+}
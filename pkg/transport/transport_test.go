@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lucasmenendez/gopsi/pkg/client"
+)
+
+// connPair function returns both sides of a loopback TCP connection.
+func connPair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	defer listener.Close()
+
+	var accepted = make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	return conn, <-accepted
+}
+
+// runPair function runs the protocol between two sessions connected through a
+// loopback TCP connection, returning the result and error of both sides. Both
+// clients trust the identity of the other one, and the responder client is
+// also initialized with the options provided.
+func runPair(t *testing.T, initData, respData []string, respOptions ...client.Option) ([]string, error, error) {
+	initConn, respConn := connPair(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	initPub, initKey, _ := ed25519.GenerateKey(rand.Reader)
+	respPub, respKey, _ := ed25519.GenerateKey(rand.Reader)
+	respOptions = append([]client.Option{client.WithIdentity(respKey), client.WithPeerIdentity(initPub)}, respOptions...)
+
+	var respErr = make(chan error, 1)
+	go func() {
+		responder, _ := client.Init(respOptions...)
+		_, err := NewSession(respConn, responder, Responder).Run(respData)
+		respErr <- err
+	}()
+
+	initiator, _ := client.Init(client.WithIdentity(initKey), client.WithPeerIdentity(respPub))
+	result, initErr := NewSession(initConn, initiator, Initiator).Run(initData)
+	return result, initErr, <-respErr
+}
+
+func TestRun(t *testing.T) {
+	var initData = []string{"hello world", "foo"}
+	var respData = []string{"bar", "hello world"}
+
+	result, initErr, respErr := runPair(t, initData, respData)
+	if initErr != nil {
+		t.Fatalf("expected nil, got %s", initErr)
+	} else if respErr != nil {
+		t.Fatalf("expected nil, got %s", respErr)
+	} else if expected := []string{"hello world"}; !reflect.DeepEqual(expected, result) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestRunUntrustedPeer(t *testing.T) {
+	peer, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, initErr, respErr := runPair(t, []string{"hello world"}, []string{"hello world"}, client.WithPeerIdentity(peer))
+
+	var perr *ProtocolError
+	if !errors.As(respErr, &perr) || perr.Remote || perr.Code != CodeInvalidPayload {
+		t.Fatalf("expected local invalid payload error, got %v", respErr)
+	} else if !errors.As(initErr, &perr) || !perr.Remote || perr.Code != CodeInvalidPayload {
+		t.Fatalf("expected remote invalid payload error, got %v", initErr)
+	}
+}
+
+func TestRunRemoteError(t *testing.T) {
+	_, initErr, respErr := runPair(t, []string{"hello world"}, nil)
+
+	var perr *ProtocolError
+	if !errors.As(respErr, &perr) || perr.Remote || perr.Code != CodeInternal {
+		t.Fatalf("expected local internal error, got %v", respErr)
+	} else if !errors.As(initErr, &perr) || !perr.Remote || perr.Code != CodeInternal {
+		t.Fatalf("expected remote internal error, got %v", initErr)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	// Read the proposal but never answer it.
+	go server.Read(make([]byte, 4096))
+
+	initiator, _ := client.Init(client.WithAnyPeer())
+	var session = NewSession(conn, initiator, Initiator)
+	session.Timeout = 50 * time.Millisecond
+
+	var perr *ProtocolError
+	if _, err := session.Run([]string{"hello world"}); !errors.As(err, &perr) || perr.Code != CodeTimeout {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestRunUnexpectedMessage(t *testing.T) {
+	connA, connB := connPair(t)
+	defer connA.Close()
+	defer connB.Close()
+
+	// Run both sides as initiators, so both receive a proposal instead of
+	// the expected response.
+	var errB = make(chan error, 1)
+	go func() {
+		clientB, _ := client.Init(client.WithAnyPeer())
+		_, err := NewSession(connB, clientB, Initiator).Run([]string{"hello world"})
+		errB <- err
+	}()
+
+	clientA, _ := client.Init(client.WithAnyPeer())
+	_, errA := NewSession(connA, clientA, Initiator).Run([]string{"hello world"})
+
+	var perr *ProtocolError
+	for _, err := range []error{errA, <-errB} {
+		if !errors.As(err, &perr) || perr.Code != CodeUnexpectedMessage {
+			t.Fatalf("expected unexpected message error, got %v", err)
+		}
+	}
+}
+
+func TestReceiveFrame(t *testing.T) {
+	// sendHeader function sends the header of a frame of the size provided
+	// with only a few bytes of its payload and closes the connection.
+	var sendHeader = func(size uint32) *Session {
+		server, conn := net.Pipe()
+		go func() {
+			var frame = []byte{msgProposal, 0, 0, 0, 0, 1, 2, 3}
+			binary.BigEndian.PutUint32(frame[1:], size)
+			server.Write(frame)
+			server.Close()
+		}()
+		return &Session{Conn: conn, MaxFrameSize: DefaultMaxFrameSize}
+	}
+
+	var perr *ProtocolError
+	if _, err := sendHeader(DefaultMaxFrameSize + 1).receive(msgProposal); !errors.As(err, &perr) || perr.Code != CodeInvalidPayload {
+		t.Fatalf("expected invalid payload error, got %v", err)
+	}
+
+	// A truncated frame of the maximum size does not allocate its size.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := sendHeader(DefaultMaxFrameSize).receive(msgProposal); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected less than 1 MiB allocated, got %d bytes", allocated)
+	}
+}
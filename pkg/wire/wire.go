@@ -0,0 +1,288 @@
+// Package wire implements a versioned binary format to share batches of
+// encrypted items ([][]*big.Int) between clients. Every stream starts with a
+// header that contains a magic string, the format version and the size of the
+// elements, followed by one or more length-prefixed batches. Each element is
+// encoded as a fixed-width big-endian integer with the size of the common
+// prime, and every decoded element is validated to be into the range [1, p-1].
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Version contains the current version of the format.
+const Version byte = 1
+
+// Default limits of the codec, used when Codec.MaxItems or Codec.MaxWords are
+// not defined.
+const (
+	DefaultMaxItems = 1 << 24
+	DefaultMaxWords = 1 << 12
+)
+
+var magic = []byte("GPSI")
+
+var (
+	// ErrFormat is returned when the input is not a valid stream.
+	ErrFormat = errors.New("wire: invalid format")
+	// ErrVersion is returned when the input version is not supported.
+	ErrVersion = errors.New("wire: unsupported version")
+	// ErrLimit is returned when a batch exceeds the codec limits.
+	ErrLimit = errors.New("wire: limit exceeded")
+	// ErrRange is returned when an element is out of the range [1, p-1].
+	ErrRange = errors.New("wire: element out of range")
+)
+
+// Codec struct contains the common prime used to size and validate the
+// encoded elements and the limits applied to the decoded batches: the maximum
+// number of items of a batch (MaxItems) and the maximum number of words of an
+// item (MaxWords). Elements that are not integers modulo the common prime,
+// such as encoded elliptic curve points, can define their own fixed size in
+// bytes (Size) and validation function (Validate), which replace the ones
+// based on the prime when they are defined.
+type Codec struct {
+	Prime    *big.Int
+	MaxItems int
+	MaxWords int
+	Size     int
+	Validate func(element *big.Int) error
+}
+
+// NewCodec function instances a Codec for the common prime provided with the
+// default limits. It returns an error if the prime is not defined.
+func NewCodec(prime *big.Int) (*Codec, error) {
+	if prime == nil || prime.Cmp(big.NewInt(2)) <= 0 {
+		return nil, errors.New("wire: invalid common prime")
+	}
+
+	return &Codec{
+		Prime:    prime,
+		MaxItems: DefaultMaxItems,
+		MaxWords: DefaultMaxWords,
+	}, nil
+}
+
+// Marshal function encodes the batch provided into a single stream, composed by
+// the header and the batch itself.
+func (c *Codec) Marshal(batch [][]*big.Int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.NewWriter(&buf).WriteBatch(batch); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal function decodes the stream provided, that must contain a single
+// batch, validating every element. It returns an error if the stream contains
+// more data after the batch.
+func (c *Codec) Unmarshal(data []byte) ([][]*big.Int, error) {
+	var reader = c.NewReader(bytes.NewReader(data))
+	batch, err := reader.ReadBatch()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing batch", ErrFormat)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err = reader.ReadBatch(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data", ErrFormat)
+	}
+	return batch, nil
+}
+
+// size function returns the number of bytes of each encoded element.
+func (c *Codec) size() int {
+	if c.Size > 0 {
+		return c.Size
+	}
+	return (c.Prime.BitLen() + 7) / 8
+}
+
+// limits function returns the current limits of the codec, using the default
+// ones if they are not defined.
+func (c *Codec) limits() (maxItems, maxWords int) {
+	if maxItems = c.MaxItems; maxItems <= 0 {
+		maxItems = DefaultMaxItems
+	}
+	if maxWords = c.MaxWords; maxWords <= 0 {
+		maxWords = DefaultMaxWords
+	}
+	return
+}
+
+// validate function checks that the element provided is into the range
+// [1, p-1], or calls the codec Validate function if it is defined.
+func (c *Codec) validate(element *big.Int) error {
+	if c.Validate != nil {
+		if element == nil || element.Sign() <= 0 || element.BitLen() > c.size()*8 || c.Validate(element) != nil {
+			return ErrRange
+		}
+		return nil
+	} else if element == nil || element.Sign() <= 0 || element.Cmp(c.Prime) >= 0 {
+		return ErrRange
+	}
+	return nil
+}
+
+// Writer struct encodes batches into the underlying io.Writer. The header is
+// written before the first batch.
+type Writer struct {
+	codec  *Codec
+	w      io.Writer
+	header bool
+}
+
+// NewWriter function instances a Writer that encodes batches with the current
+// codec into the io.Writer provided.
+func (c *Codec) NewWriter(w io.Writer) *Writer {
+	return &Writer{codec: c, w: w}
+}
+
+// WriteBatch function encodes the batch provided and writes it, preceded by the
+// stream header if it is the first one. It returns an error, before writing
+// anything, if the batch exceeds the codec limits or if some element is out of
+// range.
+func (w *Writer) WriteBatch(batch [][]*big.Int) error {
+	var maxItems, maxWords = w.codec.limits()
+	if len(batch) > maxItems {
+		return fmt.Errorf("%w: %d items", ErrLimit, len(batch))
+	}
+	for i, item := range batch {
+		if len(item) > maxWords {
+			return fmt.Errorf("%w: item %d has %d words", ErrLimit, i, len(item))
+		}
+		for _, element := range item {
+			if err := w.codec.validate(element); err != nil {
+				return fmt.Errorf("%w: item %d", err, i)
+			}
+		}
+	}
+
+	var size int = w.codec.size()
+	var buf = bufio.NewWriter(w.w)
+	if !w.header {
+		var header = append(append([]byte{}, magic...), Version, 0, 0)
+		binary.BigEndian.PutUint16(header[len(magic)+1:], uint16(size))
+		buf.Write(header)
+	}
+
+	// Write the number of items of the batch and, for each item, the number of
+	// words followed by the fixed-width words. Write errors are kept by the
+	// buffered writer and returned by Flush.
+	var word []byte = make([]byte, size)
+	var prefix []byte = make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, uint32(len(batch)))
+	buf.Write(prefix)
+	for _, item := range batch {
+		binary.BigEndian.PutUint32(prefix, uint32(len(item)))
+		buf.Write(prefix)
+		for _, element := range item {
+			buf.Write(element.FillBytes(word))
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	w.header = true
+	return nil
+}
+
+// Reader struct decodes batches from the underlying io.Reader, checking the
+// header before the first batch.
+type Reader struct {
+	codec  *Codec
+	r      *bufio.Reader
+	header bool
+}
+
+// NewReader function instances a Reader that decodes batches with the current
+// codec from the io.Reader provided.
+func (c *Codec) NewReader(r io.Reader) *Reader {
+	return &Reader{codec: c, r: bufio.NewReader(r)}
+}
+
+// ReadBatch function reads and decodes the next batch of the stream, checking
+// the header first if it is the first one. It returns io.EOF when the stream
+// ends cleanly before a new batch, and an error if the input is malformed,
+// exceeds the codec limits or contains elements out of range.
+func (r *Reader) ReadBatch() ([][]*big.Int, error) {
+	var size int = r.codec.size()
+	if !r.header {
+		var header []byte = make([]byte, len(magic)+3)
+		if _, err := io.ReadFull(r.r, header); err == io.EOF {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: truncated header", ErrFormat)
+		}
+
+		if !bytes.Equal(header[:len(magic)], magic) {
+			return nil, fmt.Errorf("%w: bad magic", ErrFormat)
+		} else if header[len(magic)] != Version {
+			return nil, fmt.Errorf("%w: %d", ErrVersion, header[len(magic)])
+		} else if int(binary.BigEndian.Uint16(header[len(magic)+1:])) != size {
+			return nil, fmt.Errorf("%w: element size mismatch", ErrFormat)
+		}
+		r.header = true
+	}
+
+	var maxItems, maxWords = r.codec.limits()
+	numItems, err := r.readUint32()
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: truncated batch", ErrFormat)
+	} else if numItems > uint32(maxItems) {
+		return nil, fmt.Errorf("%w: %d items", ErrLimit, numItems)
+	}
+
+	// Allocate the batch progressively to avoid trusting the length prefix
+	// for big allocations.
+	var batch [][]*big.Int = make([][]*big.Int, 0, minInt(int(numItems), 1024))
+	var word []byte = make([]byte, size)
+	for i := 0; i < int(numItems); i++ {
+		numWords, err := r.readUint32()
+		if err != nil {
+			return nil, fmt.Errorf("%w: truncated item %d", ErrFormat, i)
+		} else if numWords > uint32(maxWords) {
+			return nil, fmt.Errorf("%w: item %d has %d words", ErrLimit, i, numWords)
+		}
+
+		var item []*big.Int = make([]*big.Int, numWords)
+		for w := range item {
+			if _, err := io.ReadFull(r.r, word); err != nil {
+				return nil, fmt.Errorf("%w: truncated item %d", ErrFormat, i)
+			}
+
+			item[w] = new(big.Int).SetBytes(word)
+			if err := r.codec.validate(item[w]); err != nil {
+				return nil, fmt.Errorf("%w: item %d", err, i)
+			}
+		}
+		batch = append(batch, item)
+	}
+
+	return batch, nil
+}
+
+// readUint32 function reads a big-endian uint32 from the underlying reader.
+func (r *Reader) readUint32() (uint32, error) {
+	var buf []byte = make([]byte, 4)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,155 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+var prime, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	codec, err := NewCodec(prime)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if _, err = NewCodec(nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var batch = [][]*big.Int{
+		{big.NewInt(1), big.NewInt(2)},
+		{new(big.Int).Sub(prime, big.NewInt(1))},
+		{},
+	}
+	data, err := codec.Marshal(batch)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if expected := 7 + 4 + (4+2*32)*1 + (4 + 32) + 4; len(data) != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, len(data))
+	}
+
+	result, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(batch, result) {
+		t.Fatalf("expected %v, got %v", batch, result)
+	}
+
+	if _, err := codec.Marshal([][]*big.Int{{prime}}); !errors.Is(err, ErrRange) {
+		t.Fatalf("expected ErrRange, got %v", err)
+	} else if _, err := codec.Marshal([][]*big.Int{{big.NewInt(0)}}); !errors.Is(err, ErrRange) {
+		t.Fatalf("expected ErrRange, got %v", err)
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	codec, _ := NewCodec(prime)
+	data, _ := codec.Marshal([][]*big.Int{{big.NewInt(5)}})
+
+	var outOfRange = append([]byte{}, data...)
+	copy(outOfRange[len(data)-32:], prime.Bytes())
+	var zero = append([]byte{}, data...)
+	copy(zero[len(data)-32:], make([]byte, 32))
+	var badVersion = append([]byte{}, data...)
+	badVersion[4] = Version + 1
+
+	var cases = []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrFormat},
+		{"bad magic", append([]byte("XXXX"), data[4:]...), ErrFormat},
+		{"bad version", badVersion, ErrVersion},
+		{"truncated", data[:len(data)-1], ErrFormat},
+		{"trailing", append(append([]byte{}, data...), 0), ErrFormat},
+		{"out of range", outOfRange, ErrRange},
+		{"zero", zero, ErrRange},
+	}
+	for _, c := range cases {
+		if _, err := codec.Unmarshal(c.input); !errors.Is(err, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, err)
+		}
+	}
+
+	var small = &Codec{Prime: prime, MaxItems: 1, MaxWords: 1}
+	if _, err := small.Unmarshal(data); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	}
+	data, _ = codec.Marshal([][]*big.Int{{big.NewInt(5)}, {big.NewInt(6)}})
+	if _, err := small.Unmarshal(data); !errors.Is(err, ErrLimit) {
+		t.Fatalf("expected ErrLimit, got %v", err)
+	}
+	data, _ = codec.Marshal([][]*big.Int{{big.NewInt(5), big.NewInt(6)}})
+	if _, err := small.Unmarshal(data); !errors.Is(err, ErrLimit) {
+		t.Fatalf("expected ErrLimit, got %v", err)
+	} else if _, err := small.Marshal([][]*big.Int{{big.NewInt(5), big.NewInt(6)}}); !errors.Is(err, ErrLimit) {
+		t.Fatalf("expected ErrLimit, got %v", err)
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	codec, _ := NewCodec(prime)
+
+	var batches = [][][]*big.Int{
+		{{big.NewInt(1)}, {big.NewInt(2), big.NewInt(3)}},
+		{{big.NewInt(4)}},
+	}
+	var buf bytes.Buffer
+	var writer = codec.NewWriter(&buf)
+	for _, batch := range batches {
+		if err := writer.WriteBatch(batch); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		}
+	}
+
+	var reader = codec.NewReader(&buf)
+	for _, expected := range batches {
+		if batch, err := reader.ReadBatch(); err != nil {
+			t.Fatalf("expected nil, got %s", err)
+		} else if !reflect.DeepEqual(expected, batch) {
+			t.Fatalf("expected %v, got %v", expected, batch)
+		}
+	}
+	if _, err := reader.ReadBatch(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	other, _ := NewCodec(big.NewInt(65537))
+	data, _ := codec.Marshal(batches[0])
+	if _, err := other.Unmarshal(data); !errors.Is(err, ErrFormat) {
+		t.Fatalf("expected ErrFormat, got %v", err)
+	}
+}
+
+func TestCustomElements(t *testing.T) {
+	// Accept only odd elements of 3 bytes.
+	var codec = &Codec{Size: 3, Validate: func(element *big.Int) error {
+		if element.Bit(0) == 0 {
+			return errors.New("even element")
+		}
+		return nil
+	}}
+
+	var batch = [][]*big.Int{{big.NewInt(1), big.NewInt(0xffffff)}}
+	data, err := codec.Marshal(batch)
+	if err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if expected := 7 + 4 + 4 + 2*3; len(data) != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, len(data))
+	} else if result, err := codec.Unmarshal(data); err != nil {
+		t.Fatalf("expected nil, got %s", err)
+	} else if !reflect.DeepEqual(batch, result) {
+		t.Fatalf("expected %v, got %v", batch, result)
+	}
+
+	var invalid = []*big.Int{big.NewInt(2), big.NewInt(0x1000001), big.NewInt(0)}
+	for _, element := range invalid {
+		if _, err := codec.Marshal([][]*big.Int{{element}}); !errors.Is(err, ErrRange) {
+			t.Fatalf("expected ErrRange for %s, got %v", element, err)
+		}
+	}
+}